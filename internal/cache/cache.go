@@ -0,0 +1,182 @@
+// Package cache provides a simple file-backed storage of file content hashes, used to skip files that are
+// already compressed (or known to be incompressible) without spending the API quota on them again.
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FileName holds the default name of the cache file.
+const FileName = "tinifier.cache"
+
+// header is the first line of the cache file, used to detect the file format.
+const header = "# tinifier cache v1"
+
+// Kind describes why the hash is stored in the cache.
+type Kind string
+
+const (
+	// KindCompressed means the file content is the result of the compression.
+	KindCompressed Kind = "compressed"
+
+	// KindIncompressible means the file content cannot be compressed (enough) by the API.
+	KindIncompressible Kind = "incompressible"
+)
+
+// Cache is a set of file content hashes with their kinds. It's safe for concurrent use.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Kind
+	dirty   bool // true if the entries were changed since the last load/save
+}
+
+// Open loads the cache from the file at the given path. A missing file is not an error - an empty cache is
+// returned in this case (the file will be created on Save).
+func Open(path string) (*Cache, error) {
+	var c = Cache{path: path, entries: make(map[string]Kind)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &c, nil
+		}
+
+		return nil, fmt.Errorf("failed to open the cache file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var scanner = bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		var text = strings.TrimSpace(scanner.Text())
+
+		if line == 1 && text != header {
+			return nil, fmt.Errorf("unsupported cache file format (%s)", path)
+		}
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue // skip empty lines and comments
+		}
+
+		hash, kind, ok := strings.Cut(text, " ")
+		if !ok || hash == "" {
+			return nil, fmt.Errorf("malformed cache file line %d (%s)", line, path)
+		}
+
+		c.entries[hash] = Kind(kind)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the cache file: %w", err)
+	}
+
+	return &c, nil
+}
+
+// Get returns the kind of the given hash, and a boolean indicating whether the hash is in the cache.
+func (c *Cache) Get(hash string) (Kind, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kind, ok := c.entries[hash]
+
+	return kind, ok
+}
+
+// Put adds the hash with the given kind to the cache (or updates the kind of the existing one).
+func (c *Cache) Put(hash string, kind Kind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if current, ok := c.entries[hash]; !ok || current != kind {
+		c.entries[hash], c.dirty = kind, true
+	}
+}
+
+// Len returns the number of hashes in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Save writes the cache to the file (only if it was changed). The file is written atomically, using a
+// temporary file in the same directory. Missing parent directories are created.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil { //nolint:mnd
+		return fmt.Errorf("failed to create the cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary cache file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op if the file was renamed
+
+	var w = bufio.NewWriter(tmp)
+
+	_, _ = w.WriteString(header + "\n")
+
+	for _, hash := range slices.Sorted(maps.Keys(c.entries)) { // sort for the stable file content
+		_, _ = w.WriteString(hash + " " + string(c.entries[hash]) + "\n")
+	}
+
+	if err = w.Flush(); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("failed to write the cache file: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the cache file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to replace the cache file: %w", err)
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+// HashFile returns the hex-encoded SHA-256 hash of the file content.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var h = sha256.New()
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,116 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/internal/cache"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	var path = filepath.Join(t.TempDir(), "sub", "dir", cache.FileName)
+
+	c, err := cache.Open(path) // missing file is not an error
+	assertNoError(t, err)
+	assertEqual(t, 0, c.Len())
+
+	assertNoError(t, c.Save()) // nothing changed, so nothing is written
+
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Fatalf("expected the cache file to be missing, got %v", statErr)
+	}
+
+	c.Put("bbb", cache.KindIncompressible)
+	c.Put("aaa", cache.KindCompressed)
+	c.Put("bbb", cache.KindCompressed) // overwrite
+
+	kind, ok := c.Get("bbb")
+	assertEqual(t, true, ok)
+	assertEqual(t, cache.KindCompressed, kind)
+
+	_, ok = c.Get("ccc")
+	assertEqual(t, false, ok)
+
+	assertNoError(t, c.Save())
+
+	content, err := os.ReadFile(path)
+	assertNoError(t, err)
+	assertEqual(t, "# tinifier cache v1\naaa compressed\nbbb compressed\n", string(content))
+
+	// reopen
+	c, err = cache.Open(path)
+	assertNoError(t, err)
+	assertEqual(t, 2, c.Len())
+
+	kind, ok = c.Get("aaa")
+	assertEqual(t, true, ok)
+	assertEqual(t, cache.KindCompressed, kind)
+}
+
+func TestOpen_Errors(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveContent   string
+		wantErrSubstr string
+	}{
+		"unsupported format": {
+			giveContent:   "foo bar\n",
+			wantErrSubstr: "unsupported cache file format",
+		},
+		"malformed line": {
+			giveContent:   "# tinifier cache v1\nfoobar\n",
+			wantErrSubstr: "malformed cache file line 2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var path = filepath.Join(t.TempDir(), cache.FileName)
+
+			assertNoError(t, os.WriteFile(path, []byte(tc.giveContent), 0o600))
+
+			_, err := cache.Open(path)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErrSubstr) {
+				t.Fatalf("expected error to contain %q, got %v", tc.wantErrSubstr, err)
+			}
+		})
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	t.Parallel()
+
+	var path = filepath.Join(t.TempDir(), "file")
+
+	assertNoError(t, os.WriteFile(path, []byte("foo"), 0o600))
+
+	hash, err := cache.HashFile(path)
+	assertNoError(t, err)
+	assertEqual(t, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", hash)
+
+	_, err = cache.HashFile(path + "-missing")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func assertEqual[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+
+	if expected != actual {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
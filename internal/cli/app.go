@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,11 +15,13 @@ import (
 	"sync/atomic"
 	"time"
 
+	"gh.tarampamp.am/tinifier/v5/internal/cache"
 	"gh.tarampamp.am/tinifier/v5/internal/cli/cmd"
 	"gh.tarampamp.am/tinifier/v5/internal/config"
 	"gh.tarampamp.am/tinifier/v5/internal/finder"
 	"gh.tarampamp.am/tinifier/v5/internal/humanize"
 	"gh.tarampamp.am/tinifier/v5/internal/retry"
+	"gh.tarampamp.am/tinifier/v5/internal/validate"
 	"gh.tarampamp.am/tinifier/v5/internal/version"
 	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
 )
@@ -28,6 +32,9 @@ type App struct {
 	cmd   cmd.Command
 	opt   options
 	logMu sync.Mutex
+
+	stdin          io.Reader // the image source in the stdin mode
+	stdout, stderr io.Writer // the compressed image (in the stdin mode) and the logs are written here
 }
 
 func NewApp(name string) *App { //nolint:funlen
@@ -35,10 +42,13 @@ func NewApp(name string) *App { //nolint:funlen
 		cmd: cmd.Command{
 			Name:        name,
 			Description: "CLI tool for compressing images using the TinyPNG.",
-			Usage:       "[<options>] [<files-or-directories>]",
+			Usage:       "[<options>] [<files-or-directories> | -]",
 			Version:     version.Version(),
 		},
-		opt: newOptionsWithDefaults(),
+		opt:    newOptionsWithDefaults(),
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
 	}
 
 	var (
@@ -53,6 +63,46 @@ func NewApp(name string) *App { //nolint:funlen
 			Usage:   "TinyPNG API keys <https://tinypng.com/dashboard/api> (separated by commas)",
 			EnvVars: []string{"API_KEYS"},
 		}
+		keyQuotaLimit = cmd.Flag[uint64]{
+			Names: []string{"key-quota-limit"},
+			Usage: "Monthly compressions limit per API key; keys reaching it are not used anymore " +
+				"(set 0 to disable)",
+			EnvVars: []string{"KEY_QUOTA_LIMIT"},
+			Default: app.opt.KeyQuotaLimit,
+		}
+		keysStrategy = cmd.Flag[string]{
+			Names: []string{"keys-strategy"},
+			Usage: "Strategy of choosing the API key for the next file " +
+				"(most-remaining|random|round-robin|least-in-flight)",
+			EnvVars: []string{"KEYS_STRATEGY"},
+			Default: app.opt.KeysStrategy,
+			Validator: func(_ *cmd.Command, v string) error {
+				_, err := tinypng.ParsePoolStrategy(v)
+
+				return err
+			},
+		}
+		maxInFlightPerKey = cmd.Flag[uint]{
+			Names:   []string{"max-in-flight-per-key"},
+			Usage:   "Maximum number of files processed simultaneously with the same API key (set 0 to disable)",
+			EnvVars: []string{"MAX_IN_FLIGHT_PER_KEY"},
+			Default: app.opt.MaxInFlightPerKey,
+		}
+		keyCoolDown = cmd.Flag[time.Duration]{
+			Names: []string{"key-cool-down"},
+			Usage: fmt.Sprintf("For how long the rate-limited API key is not used (unless the API provides the "+
+				"delay); the key is retired if it's rate-limited again after %d consecutive cool-downs",
+				tinypng.DefaultCoolDownMaxStrike,
+			),
+			EnvVars: []string{"KEY_COOL_DOWN"},
+			Default: app.opt.KeyCoolDown,
+		}
+		apiURL = cmd.Flag[string]{
+			Names:   []string{"api-url"},
+			Usage:   "TinyPNG API base URL (e.g. a caching proxy or a local fake server)",
+			EnvVars: []string{"API_URL"},
+			Default: app.opt.ApiURL,
+		}
 		fileExtensions = cmd.Flag[string]{
 			Names:   []string{"ext", "e"},
 			Usage:   "Extensions of files to compress (separated by commas)",
@@ -66,6 +116,74 @@ func NewApp(name string) *App { //nolint:funlen
 				return nil
 			},
 		}
+		detectByContent = cmd.Flag[bool]{
+			Names: []string{"detect-by-content"},
+			Usage: "Select the images by their content (magic bytes) instead of the file extensions, and reject " +
+				"the files with the extension that does not match the content",
+			EnvVars: []string{"DETECT_BY_CONTENT"},
+			Default: app.opt.DetectByContent,
+		}
+		include = cmd.Flag[string]{
+			Names: []string{"include"},
+			Usage: "Process only the files matching these glob patterns, relative to the given directories " +
+				"(separated by commas; e.g. assets/**,**/*.png)",
+			EnvVars: []string{"INCLUDE"},
+		}
+		exclude = cmd.Flag[string]{
+			Names: []string{"exclude"},
+			Usage: "Skip the files and directories matching these glob patterns, relative to the given directories " +
+				"(separated by commas; e.g. node_modules/**,**/*.min.png)",
+			EnvVars: []string{"EXCLUDE"},
+		}
+		useGitignore = cmd.Flag[bool]{
+			Names: []string{"use-gitignore"},
+			Usage: "Skip the files ignored by the .gitignore files in the scanned directories " +
+				"(the " + finder.IgnoreFileName + " files are always honored)",
+			EnvVars: []string{"USE_GITIGNORE"},
+			Default: app.opt.UseGitignore,
+		}
+		minSize = cmd.Flag[string]{
+			Names:   []string{"min-size"},
+			Usage:   "Process only the files of at least this size (e.g. 512, 10KB or 1.5MB)",
+			EnvVars: []string{"MIN_SIZE"},
+			Validator: func(_ *cmd.Command, v string) error {
+				_, err := humanize.ParseBytes(v)
+
+				return err
+			},
+		}
+		maxSize = cmd.Flag[string]{
+			Names:   []string{"max-size"},
+			Usage:   "Process only the files of at most this size (e.g. 512, 10KB or 1.5MB)",
+			EnvVars: []string{"MAX_SIZE"},
+			Validator: func(_ *cmd.Command, v string) error {
+				_, err := humanize.ParseBytes(v)
+
+				return err
+			},
+		}
+		newerThan = cmd.Flag[string]{
+			Names: []string{"newer-than"},
+			Usage: "Process only the files modified after the given date (e.g. 2025-01-31) or within the given " +
+				"duration (e.g. 72h or 7d)",
+			EnvVars: []string{"NEWER_THAN"},
+			Validator: func(_ *cmd.Command, v string) error {
+				_, err := parseTimeBound(v)
+
+				return err
+			},
+		}
+		olderThan = cmd.Flag[string]{
+			Names: []string{"older-than"},
+			Usage: "Process only the files modified before the given date (e.g. 2025-01-31) or earlier than the " +
+				"given duration ago (e.g. 72h or 7d)",
+			EnvVars: []string{"OLDER_THAN"},
+			Validator: func(_ *cmd.Command, v string) error {
+				_, err := parseTimeBound(v)
+
+				return err
+			},
+		}
 		threatsCount = cmd.Flag[uint]{
 			Names:   []string{"threads", "t"},
 			Usage:   "Number of threads to use for compressing",
@@ -86,16 +204,66 @@ func NewApp(name string) *App { //nolint:funlen
 		}
 		delayBetweenRetries = cmd.Flag[time.Duration]{
 			Names:   []string{"delay-between-retries"},
-			Usage:   "Delay between retry attempts",
+			Usage:   "Delay between retry attempts (the initial one for the exponential backoff)",
 			EnvVars: []string{"DELAY_BETWEEN_RETRIES"},
 			Default: app.opt.DelayBetweenRetries,
 		}
+		retryBackoff = cmd.Flag[string]{
+			Names:   []string{"retry-backoff"},
+			Usage:   "Backoff strategy of the retries (fixed|exponential)",
+			EnvVars: []string{"RETRY_BACKOFF"},
+			Default: app.opt.RetryBackoff,
+			Validator: func(_ *cmd.Command, v string) error {
+				switch v {
+				case retryBackoffFixed, retryBackoffExponential:
+					return nil
+				}
+
+				return fmt.Errorf("unsupported retry backoff %q", v)
+			},
+		}
+		retryMaxDelay = cmd.Flag[time.Duration]{
+			Names:   []string{"retry-max-delay"},
+			Usage:   "Maximum delay between retry attempts, including the one requested by the server (set 0 to disable)",
+			EnvVars: []string{"RETRY_MAX_DELAY"},
+			Default: app.opt.RetryMaxDelay,
+		}
+		retryJitter = cmd.Flag[bool]{
+			Names:   []string{"retry-jitter"},
+			Usage:   "Randomize the delay between retry attempts (to spread the retries of concurrent threads)",
+			EnvVars: []string{"RETRY_JITTER"},
+			Default: app.opt.RetryJitter,
+		}
 		recursive = cmd.Flag[bool]{
 			Names:   []string{"recursive", "r"},
 			Usage:   "Search for files in listed directories recursively",
 			EnvVars: []string{"RECURSIVE"},
 			Default: app.opt.Recursive,
 		}
+		followSymlinks = cmd.Flag[bool]{
+			Names:   []string{"follow-symlinks"},
+			Usage:   "Walk into the symlinked directories during the recursive search (each file is processed once)",
+			EnvVars: []string{"FOLLOW_SYMLINKS"},
+			Default: app.opt.FollowSymlinks,
+		}
+		hidden = cmd.Flag[bool]{
+			Names:   []string{"hidden"},
+			Usage:   "Include the hidden files and directories (the names starting with a dot, e.g. .git)",
+			EnvVars: []string{"HIDDEN"},
+			Default: app.opt.Hidden,
+		}
+		maxDepth = cmd.Flag[uint]{
+			Names:   []string{"max-depth"},
+			Usage:   "Maximum depth of the recursive search (1 means only the listed directories; set 0 to disable)",
+			EnvVars: []string{"MAX_DEPTH"},
+			Default: app.opt.MaxDepth,
+		}
+		strictPaths = cmd.Flag[bool]{
+			Names:   []string{"strict-paths"},
+			Usage:   "Fail if any of the listed files or directories (or the nested ones) can't be accessed",
+			EnvVars: []string{"STRICT_PATHS"},
+			Default: app.opt.StrictPaths,
+		}
 		skipIfDiffLessThan = cmd.Flag[float64]{
 			Names:   []string{"skip-if-diff-less"},
 			Usage:   "Skip files if the diff between the original and compressed file sizes < N%",
@@ -114,20 +282,213 @@ func NewApp(name string) *App { //nolint:funlen
 			EnvVars: []string{"KEEP_ORIGINAL_FILE"},
 			Default: app.opt.KeepOriginalFile,
 		}
+		resizeMethod = cmd.Flag[string]{
+			Names:   []string{"resize-method"},
+			Usage:   "Resize the compressed images using the given method (scale/fit/cover/thumb)",
+			EnvVars: []string{"RESIZE_METHOD"},
+			Default: app.opt.ResizeMethod,
+		}
+		resizeWidth = cmd.Flag[uint]{
+			Names:   []string{"resize-width"},
+			Usage:   "Target width of the resized images, in pixels",
+			EnvVars: []string{"RESIZE_WIDTH"},
+			Default: app.opt.ResizeWidth,
+		}
+		resizeHeight = cmd.Flag[uint]{
+			Names:   []string{"resize-height"},
+			Usage:   "Target height of the resized images, in pixels",
+			EnvVars: []string{"RESIZE_HEIGHT"},
+			Default: app.opt.ResizeHeight,
+		}
+		convertTo = cmd.Flag[string]{
+			Names: []string{"convert-to"},
+			Usage: "Convert the compressed images to the given formats (webp/avif/png/jpeg, separated by commas) " +
+				"and save them next to the originals instead of replacing them",
+			EnvVars: []string{"CONVERT_TO"},
+			Validator: func(c *cmd.Command, v string) error {
+				for _, format := range cleanStrings(v, ",") {
+					if _, ok := convertMimeType(format); !ok {
+						return fmt.Errorf("unsupported conversion format %q", format)
+					}
+				}
+
+				return nil
+			},
+		}
+		convertBackground = cmd.Flag[string]{
+			Names: []string{"convert-background"},
+			Usage: "Background color for the converted images with transparency " +
+				"(white/black/hex, e.g. #0a0b0c; required for converting transparent images to jpeg)",
+			EnvVars: []string{"CONVERT_BACKGROUND"},
+			Default: app.opt.ConvertBackground,
+		}
+		storeService = cmd.Flag[string]{
+			Names:   []string{"store-service"},
+			Usage:   "Store the compressed images in the cloud storage (s3/gcs) instead of replacing the local files",
+			EnvVars: []string{"STORE_SERVICE"},
+			Default: app.opt.Store.Service,
+		}
+		storePath = cmd.Flag[string]{
+			Names: []string{"store-path"},
+			Usage: "Bucket name and the object path template for the stored images, " +
+				"e.g. bucket/images/{name} (placeholders: {name}, {base}, {ext}, {dir})",
+			EnvVars: []string{"STORE_PATH"},
+			Default: app.opt.Store.PathTemplate,
+		}
+		storeS3AccessKeyID = cmd.Flag[string]{
+			Names:   []string{"store-s3-access-key-id"},
+			Usage:   "AWS access key ID for storing in the S3",
+			EnvVars: []string{"STORE_S3_ACCESS_KEY_ID"},
+		}
+		storeS3SecretAccessKey = cmd.Flag[string]{
+			Names:   []string{"store-s3-secret-access-key"},
+			Usage:   "AWS secret access key for storing in the S3",
+			EnvVars: []string{"STORE_S3_SECRET_ACCESS_KEY"},
+		}
+		storeS3Region = cmd.Flag[string]{
+			Names:   []string{"store-s3-region"},
+			Usage:   "AWS region for storing in the S3 (e.g. us-west-1)",
+			EnvVars: []string{"STORE_S3_REGION"},
+		}
+		storeGCSAccessToken = cmd.Flag[string]{
+			Names:   []string{"store-gcs-access-token"},
+			Usage:   "GCP access token for storing in the Google Cloud Storage",
+			EnvVars: []string{"STORE_GCS_ACCESS_TOKEN"},
+		}
+		cacheFile = cmd.Flag[string]{
+			Names: []string{"cache-file"},
+			Usage: "Path to the file with hashes of already compressed (or incompressible) files, " +
+				"used to skip them without spending the API quota (bound to the --skip-if-diff-less value; used " +
+				"only when the original files are replaced in place, and not for resizing)",
+			EnvVars: []string{"CACHE_FILE"},
+			Default: app.opt.CacheFile,
+		}
+		noCache = cmd.Flag[bool]{
+			Names:   []string{"no-cache"},
+			Usage:   "Do not use the cache of already compressed files",
+			EnvVars: []string{"NO_CACHE"},
+			Default: app.opt.NoCache,
+		}
+		noDedup = cmd.Flag[bool]{
+			Names: []string{"no-dedup"},
+			Usage: "Do not de-duplicate the files with the same content (each copy is uploaded separately; " +
+				"the files are de-duplicated only when the originals are replaced, so never with the output " +
+				"directory, conversion or cloud storage)",
+			EnvVars: []string{"NO_DEDUP"},
+			Default: app.opt.NoDedup,
+		}
+		dryRun = cmd.Flag[bool]{
+			Names:   []string{"dry-run"},
+			Usage:   "Only report which files would be compressed, without uploading or modifying anything",
+			EnvVars: []string{"DRY_RUN"},
+			Default: app.opt.DryRun,
+		}
+		reportFormat = cmd.Flag[string]{
+			Names:   []string{"report"},
+			Usage:   "Write the machine-readable run report in the given format (json|ndjson|csv)",
+			EnvVars: []string{"REPORT"},
+			Validator: func(_ *cmd.Command, v string) error {
+				switch v {
+				case "", reportFormatJSON, reportFormatNDJSON, reportFormatCSV:
+					return nil
+				}
+
+				return fmt.Errorf("unsupported report format: %s", v)
+			},
+		}
+		reportFile = cmd.Flag[string]{
+			Names:   []string{"report-file"},
+			Usage:   "Path to the run report file (\"-\" means stdout; the logs are written to stderr in this case)",
+			EnvVars: []string{"REPORT_FILE"},
+			Default: app.opt.ReportFile,
+		}
+		junitReportFile = cmd.Flag[string]{
+			Names:   []string{"junit-report"},
+			Usage:   "Path to the JUnit XML report file (each file is a test case, errors are failures)",
+			EnvVars: []string{"JUNIT_REPORT"},
+		}
+		outputDir = cmd.Flag[string]{
+			Names: []string{"output-dir", "o"},
+			Usage: "Write the compressed files to this directory (mirroring the structure of the input directories) " +
+				"instead of replacing the original files",
+			EnvVars: []string{"OUTPUT_DIR"},
+		}
+		outputExists = cmd.Flag[string]{
+			Names:   []string{"output-exists"},
+			Usage:   "What to do with the files that already exist in the output directory (overwrite|skip)",
+			EnvVars: []string{"OUTPUT_EXISTS"},
+			Default: app.opt.OutputExists,
+			Validator: func(_ *cmd.Command, v string) error {
+				switch v {
+				case outputExistsOverwrite, outputExistsSkip:
+					return nil
+				}
+
+				return fmt.Errorf("unsupported policy: %s", v)
+			},
+		}
 	)
 
-	app.cmd.Flags = []cmd.Flagger{
+	app.cmd.GlobalFlags = []cmd.Flagger{
 		&configFile,
 		&apiKeys,
+		&apiURL,
+	}
+
+	app.cmd.Commands = []*cmd.Command{
+		newQuotaCommand(&configFile, &apiKeys, &apiURL),
+		newFakeServerCommand(),
+	}
+
+	app.cmd.Flags = []cmd.Flagger{
+		&keyQuotaLimit,
+		&keysStrategy,
+		&maxInFlightPerKey,
+		&keyCoolDown,
 		&fileExtensions,
+		&detectByContent,
+		&include,
+		&exclude,
+		&useGitignore,
+		&minSize,
+		&maxSize,
+		&newerThan,
+		&olderThan,
 		&threatsCount,
 		&maxErrorsToStop,
 		&retryAttempts,
 		&delayBetweenRetries,
+		&retryBackoff,
+		&retryMaxDelay,
+		&retryJitter,
 		&recursive,
+		&followSymlinks,
+		&hidden,
+		&maxDepth,
+		&strictPaths,
 		&skipIfDiffLessThan,
 		&preserveTime,
 		&keepOriginalFile,
+		&resizeMethod,
+		&resizeWidth,
+		&resizeHeight,
+		&convertTo,
+		&convertBackground,
+		&storeService,
+		&storePath,
+		&storeS3AccessKeyID,
+		&storeS3SecretAccessKey,
+		&storeS3Region,
+		&storeGCSAccessToken,
+		&cacheFile,
+		&noCache,
+		&noDedup,
+		&dryRun,
+		&reportFormat,
+		&reportFile,
+		&junitReportFile,
+		&outputDir,
+		&outputExists,
 	}
 
 	app.cmd.Action = func(ctx context.Context, c *cmd.Command, args []string) error {
@@ -146,26 +507,93 @@ func NewApp(name string) *App { //nolint:funlen
 				}
 			}
 
+			setIfFlagIsSet(&app.opt.ApiURL, apiURL)
+			setIfFlagIsSet(&app.opt.KeyQuotaLimit, keyQuotaLimit)
+			setIfFlagIsSet(&app.opt.KeysStrategy, keysStrategy)
+			setIfFlagIsSet(&app.opt.MaxInFlightPerKey, maxInFlightPerKey)
+			setIfFlagIsSet(&app.opt.KeyCoolDown, keyCoolDown)
+
 			if fileExtensions.IsSet() && fileExtensions.Value != nil {
 				if clean := cleanStrings(*fileExtensions.Value, ","); len(clean) > 0 {
 					app.opt.FileExtensions = clean
 				}
 			}
 
+			if include.IsSet() && include.Value != nil {
+				app.opt.Include = cleanStrings(*include.Value, ",")
+			}
+
+			if exclude.IsSet() && exclude.Value != nil {
+				app.opt.Exclude = cleanStrings(*exclude.Value, ",")
+			}
+
+			setIfFlagIsSet(&app.opt.DetectByContent, detectByContent)
+			setIfFlagIsSet(&app.opt.UseGitignore, useGitignore)
+
+			for _, err := range []error{
+				parseIfFlagIsSet(&app.opt.MinSize, minSize, humanize.ParseBytes),
+				parseIfFlagIsSet(&app.opt.MaxSize, maxSize, humanize.ParseBytes),
+				parseIfFlagIsSet(&app.opt.NewerThan, newerThan, parseTimeBound),
+				parseIfFlagIsSet(&app.opt.OlderThan, olderThan, parseTimeBound),
+			} {
+				if err != nil {
+					return err
+				}
+			}
+
 			setIfFlagIsSet(&app.opt.ThreadsCount, threatsCount)
 			setIfFlagIsSet(&app.opt.MaxErrorsToStop, maxErrorsToStop)
 			setIfFlagIsSet(&app.opt.RetryAttempts, retryAttempts)
 			setIfFlagIsSet(&app.opt.DelayBetweenRetries, delayBetweenRetries)
+			setIfFlagIsSet(&app.opt.RetryBackoff, retryBackoff)
+			setIfFlagIsSet(&app.opt.RetryMaxDelay, retryMaxDelay)
+			setIfFlagIsSet(&app.opt.RetryJitter, retryJitter)
 			setIfFlagIsSet(&app.opt.Recursive, recursive)
+			setIfFlagIsSet(&app.opt.FollowSymlinks, followSymlinks)
+			setIfFlagIsSet(&app.opt.Hidden, hidden)
+			setIfFlagIsSet(&app.opt.MaxDepth, maxDepth)
+			setIfFlagIsSet(&app.opt.StrictPaths, strictPaths)
 			setIfFlagIsSet(&app.opt.SkipIfDiffLessThan, skipIfDiffLessThan)
 			setIfFlagIsSet(&app.opt.PreserveTime, preserveTime)
 			setIfFlagIsSet(&app.opt.KeepOriginalFile, keepOriginalFile)
+			setIfFlagIsSet(&app.opt.ResizeMethod, resizeMethod)
+			setIfFlagIsSet(&app.opt.ResizeWidth, resizeWidth)
+			setIfFlagIsSet(&app.opt.ResizeHeight, resizeHeight)
+
+			if convertTo.IsSet() && convertTo.Value != nil {
+				app.opt.ConvertTo = cleanStrings(*convertTo.Value, ",")
+			}
+
+			setIfFlagIsSet(&app.opt.ConvertBackground, convertBackground)
+			setIfFlagIsSet(&app.opt.Store.Service, storeService)
+			setIfFlagIsSet(&app.opt.Store.PathTemplate, storePath)
+			setIfFlagIsSet(&app.opt.Store.S3AccessKeyID, storeS3AccessKeyID)
+			setIfFlagIsSet(&app.opt.Store.S3SecretAccessKey, storeS3SecretAccessKey)
+			setIfFlagIsSet(&app.opt.Store.S3Region, storeS3Region)
+			setIfFlagIsSet(&app.opt.Store.GCSAccessToken, storeGCSAccessToken)
+			setIfFlagIsSet(&app.opt.DryRun, dryRun)
+			setIfFlagIsSet(&app.opt.CacheFile, cacheFile)
+			setIfFlagIsSet(&app.opt.NoCache, noCache)
+			setIfFlagIsSet(&app.opt.NoDedup, noDedup)
+			setIfFlagIsSet(&app.opt.ReportFormat, reportFormat)
+			setIfFlagIsSet(&app.opt.ReportFile, reportFile)
+			setIfFlagIsSet(&app.opt.JUnitReportFile, junitReportFile)
+			setIfFlagIsSet(&app.opt.OutputDir, outputDir)
+			setIfFlagIsSet(&app.opt.OutputExists, outputExists)
 		}
 
 		if err := app.opt.Validate(); err != nil {
 			return fmt.Errorf("invalid options: %w", err)
 		}
 
+		if stdinRequested(args) {
+			return app.compressStdin(ctx, app.stdin, app.stdout)
+		}
+
+		if app.opt.DryRun {
+			return app.dryRun(ctx, args)
+		}
+
 		return app.run(ctx, args)
 	}
 
@@ -181,6 +609,22 @@ func setIfFlagIsSet[T cmd.FlagType](target *T, source cmd.Flag[T]) {
 	*target = *source.Value
 }
 
+// parseIfFlagIsSet parses the flag value and sets the result to the target, if the flag is set.
+func parseIfFlagIsSet[T any](target *T, source cmd.Flag[string], parse func(string) (T, error)) error {
+	if target == nil || source.Value == nil || !source.IsSet() {
+		return nil
+	}
+
+	v, err := parse(*source.Value)
+	if err != nil {
+		return err
+	}
+
+	*target = v
+
+	return nil
+}
+
 // cleanStrings splits the input string by the separator and removes empty strings and spaces.
 func cleanStrings(in, sep string) []string {
 	var out = strings.Split(in, sep)
@@ -199,11 +643,134 @@ func cleanStrings(in, sep string) []string {
 }
 
 // Run runs the application.
-func (a *App) Run(ctx context.Context, args []string) error { return a.cmd.Run(ctx, args) }
+func (a *App) Run(ctx context.Context, args []string) error {
+	return a.cmd.Run(ctx, args)
+}
 
 // Help returns the application's help message.
 func (a *App) Help() string { return a.cmd.Help() }
 
+// findFiles returns a sequence of files to process, found in the given paths using the application options
+// (and the extra finder options).
+func (a *App) findFiles(ctx context.Context, paths []string, extra ...finder.Option) (iter.Seq[string], error) {
+	var ignoreFiles = []string{finder.IgnoreFileName}
+
+	if a.opt.UseGitignore {
+		ignoreFiles = []string{".gitignore", finder.IgnoreFileName} // the latter file rules have priority
+	}
+
+	var opts = []finder.Option{
+		finder.WithRecursive(a.opt.Recursive),
+		finder.WithFollowSymlinks(a.opt.FollowSymlinks),
+		finder.WithSkipHidden(!a.opt.Hidden),
+		finder.WithMaxDepth(a.opt.MaxDepth),
+		finder.WithIgnoreFiles(ignoreFiles...),
+	}
+
+	if !a.opt.DetectByContent {
+		opts = append(opts, finder.WithFilter(finder.FilterByExt(false, a.opt.FileExtensions...)))
+	}
+
+	if a.opt.MinSize > 0 || a.opt.MaxSize > 0 {
+		opts = append(opts, finder.WithFilter(finder.FilterBySize(a.opt.MinSize, a.opt.MaxSize)))
+	}
+
+	if !a.opt.NewerThan.IsZero() || !a.opt.OlderThan.IsZero() {
+		opts = append(opts, finder.WithFilter(finder.FilterByModTime(a.opt.NewerThan, a.opt.OlderThan)))
+	}
+
+	if len(a.opt.Include) > 0 {
+		fn, err := finder.FilterByGlob(a.opt.Include...)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, finder.WithFilter(fn))
+	}
+
+	if len(a.opt.Exclude) > 0 {
+		fn, err := finder.FilterOutByGlob(a.opt.Exclude...)
+		if err != nil {
+			return nil, err
+		}
+
+		// the excluded directories are not walked into
+		opts = append(opts, finder.WithFilter(fn), finder.WithDirFilter(fn))
+	}
+
+	if a.opt.DetectByContent { // the content is checked last, since it requires reading the file
+		var mimeTypes = make([]string, 0, len(a.opt.FileExtensions))
+
+		for _, ext := range a.opt.FileExtensions {
+			if mimeType, ok := convertMimeType(ext); ok {
+				mimeTypes = append(mimeTypes, mimeType)
+			}
+		}
+
+		opts = append(opts, finder.WithFilter(finder.FilterByContent(mimeTypes...)))
+	}
+
+	return finder.Files(ctx, paths, append(opts, extra...)...), nil
+}
+
+// diagnosePaths returns the finder option to log the paths skipped because of the errors (e.g. the given path
+// does not exist), counting them. In the strict paths mode, the stop function is called too.
+func (a *App) diagnosePaths(counter *atomic.Uint64, stop func()) finder.Option {
+	return finder.WithDiagnostics(func(d finder.Diagnostic) {
+		counter.Add(1)
+
+		if a.opt.StrictPaths {
+			a.errorf("Path error: %s", d)
+
+			stop()
+
+			return
+		}
+
+		a.errorf("Path skipped: %s", d)
+	})
+}
+
+// strictPathsError returns the error if any path was skipped in the strict paths mode.
+func (a *App) strictPathsError(skipped uint64) error {
+	if !a.opt.StrictPaths || skipped == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d path(s) can't be accessed (strict paths mode)", skipped)
+}
+
+// newClientsPool creates the pool of API clients using the application options.
+func (a *App) newClientsPool() *tinypng.ClientsPool {
+	return tinypng.NewClientsPoolWithOptions(a.opt.ApiKeys,
+		tinypng.WithPoolClientOptions(tinypng.WithBaseURL(a.opt.ApiURL)),
+		tinypng.WithPoolQuotaLimit(a.opt.KeyQuotaLimit),
+		tinypng.WithPoolStrategy(tinypng.PoolStrategy(a.opt.KeysStrategy)),
+		tinypng.WithPoolMaxInFlight(a.opt.MaxInFlightPerKey),
+		tinypng.WithPoolCoolDown(a.opt.KeyCoolDown, tinypng.DefaultCoolDownMaxStrike),
+	)
+}
+
+// openCache opens the cache of already compressed files. The cache is used only when the original files are
+// replaced in place with the compressed ones (a skipped file produces no output in the output directory, the
+// conversion and cloud storage modes), and not for resizing (an already compressed file still needs to be
+// resized), so nil is returned if it's disabled or not applicable.
+func (a *App) openCache() (*cache.Cache, error) {
+	if a.opt.NoCache || a.opt.CacheFile == "" || a.opt.ResizeMethod != "" || a.opt.OutputDir != "" ||
+		len(a.opt.ConvertTo) > 0 || a.opt.Store.Service != "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return cache.Open(a.opt.CacheFile)
+}
+
+// cacheKey returns the cache key for the file content hash. The key includes the options the cached result
+// depends on (the minimal size diff decides whether the file is incompressible), so a run with other values
+// does not reuse the entries of the previous runs.
+func (a *App) cacheKey(hash string) string {
+	return hash + "@" + strconv.FormatFloat(a.opt.SkipIfDiffLessThan, 'f', -1, 64)
+}
+
 // run executes the main logic of the application.
 func (a *App) run(pCtx context.Context, paths []string) error { //nolint:gocognit,funlen,gocyclo
 	var ctx, cancel = context.WithCancel(pCtx)
@@ -212,14 +779,29 @@ func (a *App) run(pCtx context.Context, paths []string) error { //nolint:gocogni
 	var iterCtx, cancelIter = context.WithCancel(ctx)
 	defer cancelIter() // stopping the iterator
 
+	var skippedPaths atomic.Uint64 // the paths skipped by the finder because of the errors
+
+	filesSeq, findErr := a.findFiles(iterCtx, paths, a.diagnosePaths(&skippedPaths, cancelIter))
+	if findErr != nil {
+		return findErr
+	}
+
+	if err := a.strictPathsError(skippedPaths.Load()); err != nil { // the given paths are already checked
+		return err
+	}
+
+	// the files are counted using the separate sequence, so the skipped paths are not reported twice
+	countSeq, _ := a.findFiles(iterCtx, paths) // the error is already checked
+
 	var (
-		filesSeq    = finder.Files(iterCtx, paths, a.opt.Recursive, finder.FilterByExt(false, a.opt.FileExtensions...))
 		totalAmount atomic.Uint64
+		startedAt   = time.Now()
+		roots       = absPaths(paths) // used to mirror the directory structure in the output directory
 	)
 
 	// count total files in the background to prevent blocking the main process
 	go func(count uint64) {
-		for range filesSeq { // iterator respects the context, so no extra checks are needed
+		for range countSeq { // iterator respects the context, so no extra checks are needed
 			count++
 		}
 
@@ -267,134 +849,514 @@ func (a *App) run(pCtx context.Context, paths []string) error { //nolint:gocogni
 		}
 	}()
 
+	hashCache, cacheErr := a.openCache()
+	if cacheErr != nil {
+		return cacheErr
+	}
+
+	if hashCache != nil {
+		defer func() {
+			if err := hashCache.Save(); err != nil {
+				a.errorf("Failed to save the cache: %s", err)
+			}
+		}()
+	}
+
 	var (
-		pool        = tinypng.NewClientsPool(a.opt.ApiKeys)
+		pool        = a.newClientsPool()
 		guard       = make(chan struct{}, max(1, a.opt.ThreadsCount))
 		stats       fileStats
 		wg          sync.WaitGroup // ensures all jobs are complete before exiting
 		fileCounter uint64
+		dups        *dupGroups        // nil if the files de-duplication is disabled
+		claimed     map[string]string // output path -> input path (only when the output directory is set)
 
 		once sync.Once
 	)
 
-	for path := range filesSeq {
-		once.Do(func() {
-			a.logf(
-				"Compression process has started (%s). Please be patient...",
-				strings.Join([]string{
-					fmt.Sprintf("keys = %d", len(a.opt.ApiKeys)),
-					fmt.Sprintf("threads = %d", a.opt.ThreadsCount),
-					fmt.Sprintf("time preservation = %t", a.opt.PreserveTime),
-				}, ", "),
-			)
-		})
+	if a.opt.DedupFiles() {
+		dups = newDupGroups()
+	}
 
-		func() { guard <- struct{}{}; wg.Add(1) }() // acquire a concurrency slot
+	if a.opt.OutputDir != "" {
+		claimed = make(map[string]string)
+	}
 
-		fileCounter++
+	// progress returns the "[current/total]" prefix for the log messages
+	var progress = func(fileCounter uint64) string {
+		if total := totalAmount.Load(); total > 0 {
+			width := len(strconv.FormatUint(total, 10))
 
-		go func(fileCounter uint64, path string) {
-			defer func() { <-guard; wg.Done() }()
+			return fmt.Sprintf("[%0*d/%d]", width, fileCounter, total)
+		}
 
-			var filename = filepath.Base(path)
+		return fmt.Sprintf("[%d/⏳]", fileCounter)
+	}
 
-			stat, statErr := os.Stat(path)
-			if statErr != nil {
-				errs <- fmt.Errorf("failed to get the file info (%s): %w", filename, statErr)
+	// compress processes the file (the inputHash is the content hash, if it's already calculated), sharing the
+	// result with the duplicates of the group (if the file is the group leader)
+	var compress = func(fileCounter uint64, path, inputHash string, group *dupGroup) {
+		var (
+			filename = filepath.Base(path)
+			fStat    = fileStat{Path: path}
+			record   = true // whether the fStat should be added to the stats on exit
+			start    = time.Now()
+		)
 
-				return
+		defer func() {
+			if record {
+				fStat.Duration = time.Since(start)
+				stats.Add(fStat)
 			}
+		}()
 
-			var fStat = fileStat{
-				Path:     path,
-				OrigSize: uint64(stat.Size()), //nolint:gosec
-			}
+		if group != nil { // share the result with the duplicates
+			defer func() { group.Result = fStat }()
+		}
 
-			var comp *tinypng.Compressed
+		// fail reports the error and marks the file as failed
+		var fail = func(err error) { fStat.Err = err; errs <- err }
 
-			for { // attempt file upload with retries if necessary
-				client, revoke, clientFound := pool.Get()
-				if !clientFound || client == nil { // no clients available in the pool
-					errs <- errors.New("no valid API keys available")
-					cancelIter() //nolint:wsl_v5
+		stat, statErr := os.Stat(path)
+		if statErr != nil {
+			fail(fmt.Errorf("failed to get the file info (%s): %w", filename, statErr))
+
+			return
+		}
+
+		fStat.OrigSize = uint64(stat.Size()) //nolint:gosec
+
+		if a.opt.DetectByContent {
+			if _, err := detectImageType(path); err != nil {
+				fail(fmt.Errorf("file rejected (%s): %w", filename, err))
+
+				return
+			}
+		}
+
+		if hashCache != nil {
+			if inputHash == "" { // not calculated during the files discovery
+				hash, hashErr := cache.HashFile(path)
+				if hashErr != nil {
+					fail(fmt.Errorf("failed to calculate the file hash (%s): %w", filename, hashErr))
 
 					return
 				}
 
-				var cErr error
+				inputHash = hash
+			}
+
+			// skip the files that are already compressed or known to be incompressible
+			if _, cached := hashCache.Get(a.cacheKey(inputHash)); cached {
+				fStat.Skipped, fStat.Cached, fStat.SkipReason = true, true, skipReasonCached
+
+				return
+			}
+		}
 
-				comp, cErr = a.uploadFile(ctx, path, client)
-				if cErr != nil {
-					if errors.Is(cErr, tinypng.ErrUnauthorized) || errors.Is(cErr, tinypng.ErrTooManyRequests) {
-						revoke() // revoke the client if it's unauthorized or rate-limited
+		var outPath = path // where the compressed file is written (the original file is replaced by default)
 
-						continue // try to get a new client and retry uploading the file
-					}
+		if a.opt.OutputDir != "" {
+			outPath = filepath.Join(a.opt.OutputDir, relativeToRoots(roots, path))
 
-					errs <- fmt.Errorf("failed to upload (%s): %w", filename, cErr)
+			// skip the files that already exist in the output directory (before spending the API quota on them)
+			if a.opt.OutputExists == outputExistsSkip && len(a.opt.ConvertTo) == 0 {
+				if _, existsErr := os.Stat(outPath); existsErr == nil {
+					fStat.Skipped, fStat.SkipReason = true, skipReasonOutputExists
 
 					return
 				}
-
-				break // exit the loop if the file was uploaded successfully
 			}
+		}
 
-			fStat.CompSize = comp.Size
-			fStat.Type = comp.Type
+		var (
+			comp  *tinypng.Compressed
+			lease *tinypng.Lease // the key used to upload the file, the follow-up requests use the same key
+		)
 
-			// proceed only if compressed file meets criteria:
-			// - compressed file size is not 0
-			// - compressed file size is less than the original one
-			// - the difference between the original and compressed file sizes is greater than N%
-			if comp.Size == 0 ||
-				int64(comp.Size) >= stat.Size() || //nolint:gosec
-				((float64(stat.Size())-float64(comp.Size))/float64(comp.Size))*100 < a.opt.SkipIfDiffLessThan {
-				fStat.Skipped = true
-				stats.Add(fStat)
+		for { // attempt file upload with retries if necessary
+			var leaseErr error
+
+			lease, leaseErr = pool.Acquire(ctx) // blocks while all the keys are saturated
+			if leaseErr != nil {
+				if errors.Is(leaseErr, tinypng.ErrNoClients) { // no clients available in the pool
+					fail(leaseErr)
+					cancelIter() //nolint:wsl_v5
+				} else {
+					fail(fmt.Errorf("failed to get an API client (%s): %w", filename, leaseErr))
+				}
 
 				return
 			}
 
-			var tmpFilePath = path + ".tiny"
+			var cErr error
+
+			fStat.Key = lease.Client.ApiKey()
+
+			comp, cErr = a.uploadFile(ctx, path, lease.Client)
+			if cErr != nil {
+				if classifyError(cErr) == errActionRotate {
+					// retire the unauthorized key, or put the rate-limited one on the cool-down
+					lease.Fail(cErr)
 
-			defer func() { // remove the temporary file if it exists
-				if _, tmpStatErr := os.Stat(tmpFilePath); tmpStatErr == nil {
-					_ = os.Remove(tmpFilePath)
+					continue // try to get a new client and retry uploading the file
 				}
-			}()
 
-			// download the compressed file and save it to the temporary file
-			if err := a.downloadCompressed(ctx, comp, tmpFilePath); err != nil {
-				errs <- fmt.Errorf("failed to download the compressed file (%s): %w", filename, err)
+				// the file is skipped (the transient errors are already retried)
+				lease.Release()
+				fail(fmt.Errorf("failed to upload (%s): %w", filename, cErr))
 
 				return
 			}
 
-			if err := a.replaceFiles(ctx, path, tmpFilePath); err != nil {
-				errs <- fmt.Errorf("failed to replace (%s): %w", filename, err)
+			defer lease.Release() // the key is in use until the file is processed (downloaded or stored)
 
-				return
+			break // exit the loop if the file was uploaded successfully
+		}
+
+		// failKey handles the failed follow-up request (download, conversion or storing) the same way as the
+		// failed upload - the unauthorized key is retired, and the rate-limited one is put on the cool-down
+		var failKey = func(err error) {
+			if classifyError(err) == errActionRotate {
+				lease.Fail(err) // the deferred lease release is a no-op after this
 			}
+		}
 
-			a.logf(
-				"%s File %s compressed (%s → %s / %s, %s)",
-				func() string {
-					if total := totalAmount.Load(); total > 0 {
-						width := len(strconv.FormatUint(total, 10))
+		fStat.CompSize = comp.Size
+		fStat.Type = comp.Type
 
-						return fmt.Sprintf("[%0*d/%d]", width, fileCounter, total)
-					}
+		if a.opt.Store.Service != "" { // in cloud storage mode the original file stays untouched
+			location, err := a.storeCompressed(ctx, comp, path)
+			if err != nil {
+				failKey(err)
+				fail(fmt.Errorf("failed to store (%s): %w", filename, err))
 
-					return fmt.Sprintf("[%d/⏳]", fileCounter)
-				}(),
+				return
+			}
+
+			a.logf("%s File %s compressed and stored (%s → %s / %s, %s): %s",
+				progress(fileCounter),
 				filename,
 				humanize.Bytes(stat.Size()),
 				humanize.Bytes(comp.Size),
 				humanize.BytesDiff(comp.Size, stat.Size()),
 				humanize.PercentageDiff(comp.Size, stat.Size()),
+				location,
 			)
 
+			return
+		}
+
+		if len(a.opt.ConvertTo) > 0 { // in conversion mode the original file stays untouched
+			record = false // each converted file is recorded separately
+
+			var origMime, _ = convertMimeType(strings.TrimPrefix(filepath.Ext(path), "."))
+
+			for _, format := range a.opt.ConvertTo {
+				var mimeType, _ = convertMimeType(format)
+
+				if strings.EqualFold(mimeType, origMime) {
+					continue // skip the conversion to the same format
+				}
+
+				var convStart = time.Now()
+
+				convPath, outSize, err := a.convertFile(ctx, outPath, stat, comp, format)
+				if err != nil {
+					if errors.Is(err, errOutputExists) {
+						stats.Add(fileStat{
+							Path:       path,
+							Type:       mimeType,
+							OrigSize:   fStat.OrigSize,
+							Skipped:    true,
+							SkipReason: skipReasonOutputExists,
+						})
+
+						continue
+					}
+
+					failKey(err)
+
+					err = fmt.Errorf("failed to convert (%s) to %s: %w", filename, format, err)
+					errs <- err
+
+					stats.Add(fileStat{
+						Path:     path,
+						Type:     mimeType,
+						OrigSize: fStat.OrigSize,
+						Key:      fStat.Key,
+						Err:      err,
+						Duration: time.Since(convStart),
+					})
+
+					continue
+				}
+
+				a.logf("%s File %s converted to %s (%s → %s / %s, %s)",
+					progress(fileCounter),
+					filename,
+					filepath.Base(convPath),
+					humanize.Bytes(stat.Size()),
+					humanize.Bytes(outSize),
+					humanize.BytesDiff(outSize, stat.Size()),
+					humanize.PercentageDiff(outSize, stat.Size()),
+				)
+
+				stats.Add(fileStat{
+					Path:     convPath,
+					Type:     mimeType,
+					OrigSize: fStat.OrigSize,
+					CompSize: outSize,
+					Key:      fStat.Key,
+					Duration: time.Since(convStart),
+				})
+			}
+
+			return
+		}
+
+		// proceed only if compressed file meets criteria (the resized images are always proceeded, since the
+		// size of the compressed image is known only before resizing):
+		// - compressed file size is not 0
+		// - compressed file size is less than the original one
+		// - the difference between the original and compressed file sizes is greater than N%
+		if a.opt.ResizeMethod == "" && (comp.Size == 0 ||
+			int64(comp.Size) >= stat.Size() || //nolint:gosec
+			((float64(stat.Size())-float64(comp.Size))/float64(comp.Size))*100 < a.opt.SkipIfDiffLessThan) {
+			fStat.Skipped, fStat.SkipReason = true, skipReasonNotWorth
+
+			if hashCache != nil {
+				hashCache.Put(a.cacheKey(inputHash), cache.KindIncompressible)
+			}
+
+			return
+		}
+
+		var tmpFilePath = outPath + ".tiny"
+
+		if outPath != path {
+			if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil { //nolint:mnd
+				fail(fmt.Errorf("failed to create the output directory (%s): %w", filename, err))
+
+				return
+			}
+		}
+
+		defer func() { // remove the temporary file if it exists
+			if _, tmpStatErr := os.Stat(tmpFilePath); tmpStatErr == nil {
+				_ = os.Remove(tmpFilePath)
+			}
+		}()
+
+		// download the compressed file and save it to the temporary file
+		if err := a.downloadCompressed(ctx, comp, tmpFilePath); err != nil {
+			failKey(err)
+			fail(fmt.Errorf("failed to download the compressed file (%s): %w", filename, err))
+
+			return
+		}
+
+		// the resized image size differs from the reported one, so we need to get the real size
+		if tmpStat, tmpStatErr := os.Stat(tmpFilePath); tmpStatErr == nil {
+			fStat.CompSize = uint64(tmpStat.Size()) //nolint:gosec
+		}
+
+		var outputHash string // content hash of the compressed file (empty if the cache is disabled)
+
+		if hashCache != nil {
+			if hash, hashErr := cache.HashFile(tmpFilePath); hashErr == nil {
+				outputHash = hash
+			}
+		}
+
+		if outPath != path { // the original file stays untouched, the compressed one goes to the output directory
+			if err := a.moveToOutput(stat, tmpFilePath, outPath); err != nil {
+				if errors.Is(err, errOutputExists) {
+					fStat.Skipped, fStat.SkipReason = true, skipReasonOutputExists
+				} else {
+					fail(fmt.Errorf("failed to write (%s) to the output directory: %w", filename, err))
+				}
+
+				return
+			}
+		} else if err := a.replaceFiles(ctx, path, tmpFilePath); err != nil {
+			fail(fmt.Errorf("failed to replace (%s): %w", filename, err))
+
+			return
+		}
+
+		if outputHash != "" {
+			hashCache.Put(a.cacheKey(outputHash), cache.KindCompressed)
+		}
+
+		a.logf(
+			"%s File %s compressed (%s → %s / %s, %s)",
+			progress(fileCounter),
+			filename,
+			humanize.Bytes(stat.Size()),
+			humanize.Bytes(fStat.CompSize),
+			humanize.BytesDiff(fStat.CompSize, stat.Size()),
+			humanize.PercentageDiff(fStat.CompSize, stat.Size()),
+		)
+	}
+
+	// reuse processes the duplicate of the group leader, copying the compressed leader file over it. It returns
+	// false if the leader was not processed (e.g. it failed), so the duplicate should be compressed on its own.
+	var reuse = func(fileCounter uint64, path string, group *dupGroup) bool {
+		var res = group.Result // the leader processing result
+
+		if res.Err != nil {
+			return false
+		}
+
+		var (
+			filename = filepath.Base(path)
+			fStat    = fileStat{Path: path, Type: res.Type, DuplicateOf: group.Leader}
+			start    = time.Now()
+		)
+
+		defer func() {
+			fStat.Duration = time.Since(start)
 			stats.Add(fStat)
+		}()
+
+		// fail reports the error and marks the file as failed
+		var fail = func(err error) { fStat.Err = err; errs <- err }
+
+		stat, statErr := os.Stat(path)
+		if statErr != nil {
+			fail(fmt.Errorf("failed to get the file info (%s): %w", filename, statErr))
+
+			return true
+		}
+
+		fStat.OrigSize = uint64(stat.Size()) //nolint:gosec
+
+		if a.opt.DetectByContent { // the content is the same, but the extension may differ
+			if _, err := detectImageType(path); err != nil {
+				fail(fmt.Errorf("file rejected (%s): %w", filename, err))
+
+				return true
+			}
+		}
+
+		if res.Skipped { // the same content is already compressed (cached) or not worth compressing
+			fStat.Skipped, fStat.Cached, fStat.SkipReason = true, res.Cached, res.SkipReason
+
+			return true
+		}
+
+		// the hard link to the leader file is already compressed
+		if leaderStat, err := os.Stat(group.Leader); err == nil && os.SameFile(stat, leaderStat) {
+			fStat.Skipped, fStat.SkipReason = true, skipReasonHardLink
+
+			return true
+		}
+
+		if err := a.replaceFiles(ctx, path, group.Leader); err != nil {
+			fail(fmt.Errorf("failed to replace (%s): %w", filename, err))
+
+			return true
+		}
+
+		fStat.CompSize = res.CompSize
+
+		a.logf(
+			"%s File %s compressed as a duplicate of %s (%s → %s / %s, %s)",
+			progress(fileCounter),
+			filename,
+			filepath.Base(group.Leader),
+			humanize.Bytes(stat.Size()),
+			humanize.Bytes(fStat.CompSize),
+			humanize.BytesDiff(fStat.CompSize, stat.Size()),
+			humanize.PercentageDiff(fStat.CompSize, stat.Size()),
+		)
+
+		return true
+	}
+
+	// process hashes the file and processes it as the group leader or the duplicate (if the de-duplication is
+	// enabled). It's called holding the concurrency slot, and releases it on exit.
+	var process = func(fileCounter uint64, path string) {
+		var hash string
+
+		if dups != nil {
+			if h, hashErr := cache.HashFile(path); hashErr == nil { // on error, the file is processed as usual
+				hash = h
+			}
+		}
+
+		if hash == "" {
+			defer func() { <-guard }()
+
+			compress(fileCounter, path, "", nil)
+
+			return
+		}
+
+		var group, leader = dups.Track(hash, path)
+
+		if leader {
+			defer func() { <-guard }()
+			defer close(group.Done) // the result is set by the compress function
+
+			compress(fileCounter, path, hash, group)
+
+			return
+		}
+
+		<-guard // the duplicate waits for the leader result without taking a concurrency slot
+
+		select {
+		case <-group.Done:
+		case <-ctx.Done():
+			return
+		}
+
+		if reuse(fileCounter, path, group) || iterCtx.Err() != nil {
+			return // the duplicate is processed, or the process is stopping
+		}
+
+		// the leader was not processed, so the duplicate is compressed on its own
+		func() { guard <- struct{}{} }()
+		defer func() { <-guard }()
+
+		compress(fileCounter, path, hash, nil)
+	}
+
+	for path := range filesSeq {
+		once.Do(func() {
+			a.logf(
+				"Compression process has started (%s). Please be patient...",
+				strings.Join([]string{
+					fmt.Sprintf("keys = %d", len(a.opt.ApiKeys)),
+					fmt.Sprintf("threads = %d", a.opt.ThreadsCount),
+					fmt.Sprintf("time preservation = %t", a.opt.PreserveTime),
+					fmt.Sprintf("resizing = %t", a.opt.ResizeMethod != ""),
+					fmt.Sprintf("conversion = %t", len(a.opt.ConvertTo) > 0),
+					fmt.Sprintf("cloud storage = %t", a.opt.Store.Service != ""),
+				}, ", "),
+			)
+		})
+
+		fileCounter++
+
+		// the files are claimed in the discovery order, so the same file always wins the output path
+		if claimed != nil {
+			if err := claimOutputs(claimed, a.outputPaths(roots, path), path); err != nil {
+				errs <- err
+				stats.Add(fileStat{Path: path, Err: err})
+
+				continue
+			}
+		}
+
+		func() { guard <- struct{}{}; wg.Add(1) }() // acquire a concurrency slot
+
+		go func(fileCounter uint64, path string) {
+			defer wg.Done()
+
+			process(fileCounter, path)
 		}(fileCounter, path)
 	}
 
@@ -407,9 +1369,85 @@ func (a *App) run(pCtx context.Context, paths []string) error { //nolint:gocogni
 		a.logf("\n%s", table)
 	}
 
+	if dupes := stats.Duplicates(); dupes > 0 {
+		a.logf("%d duplicate file(s) reused the compression results, API compressions saved: %d",
+			dupes,
+			uint(dupes)*a.opt.CompressionsPerFile(""), // the files are not converted when de-duplicated
+		)
+	}
+
+	for _, retired := range pool.Retired() {
+		a.errorf("API key %s was retired: %s", maskApiKey(retired.Key), retired.Reason)
+	}
+
+	if err := a.report(&stats, time.Since(startedAt)); err != nil {
+		return err
+	}
+
+	if err := a.strictPathsError(skippedPaths.Load()); err != nil {
+		return err
+	}
+
 	return ctx.Err()
 }
 
+// retryOptions returns the retry options (backoff strategy and jitter) set by the user, with the extra options
+// appended.
+func (a *App) retryOptions(extra ...retry.Option) []retry.Option {
+	var opts = make([]retry.Option, 0, 3+len(extra)) //nolint:mnd
+
+	if a.opt.RetryBackoff == retryBackoffExponential {
+		opts = append(opts, retry.WithExponentialBackoff(a.opt.DelayBetweenRetries, a.opt.RetryMaxDelay))
+	} else {
+		opts = append(opts,
+			retry.WithDelayBetweenAttempts(a.opt.DelayBetweenRetries),
+			retry.WithMaxDelay(a.opt.RetryMaxDelay),
+		)
+	}
+
+	if a.opt.RetryJitter {
+		opts = append(opts, retry.WithJitter())
+	}
+
+	return append(opts, extra...)
+}
+
+// apiRetryOptions returns the retry options for the API requests (only the transient errors are retried).
+func (a *App) apiRetryOptions() []retry.Option {
+	return a.retryOptions(retry.WithRetryable(func(err error) bool { return classifyError(err) == errActionRetry }))
+}
+
+// errorAction describes how to handle the failed API request.
+type errorAction = byte
+
+const (
+	errActionRetry  errorAction = iota // transient error (server error, network issue) - retry the request
+	errActionSkip                      // the file cannot be processed (e.g. unsupported format) - skip the file
+	errActionRotate                    // the API key cannot be used (invalid or rate-limited) - use another key
+)
+
+// classifyError decides how to handle the failed API request, based on the error returned by the API client. There
+// is no "abort" action: the unusable keys are rotated out of the pool, and the process is stopped once the pool
+// has no keys left (see [tinypng.ErrNoClients]).
+func classifyError(err error) errorAction {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return errActionSkip // no sense to retry
+	}
+
+	if apiErr, ok := errors.AsType[*tinypng.APIError](err); ok {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusUnauthorized, code == http.StatusTooManyRequests:
+			return errActionRotate
+		case code == http.StatusRequestTimeout, code >= http.StatusInternalServerError:
+			return errActionRetry
+		default: // other 4xx errors are caused by the file itself (unsupported format, too big image, etc.)
+			return errActionSkip
+		}
+	}
+
+	return errActionRetry // network issues and other unexpected errors
+}
+
 // Step 1 is uploadFile - it uploads the file to the tinypng.com.
 func (a *App) uploadFile(ctx context.Context, path string, c *tinypng.Client) (res *tinypng.Compressed, _ error) {
 	return res, retry.Try(
@@ -430,8 +1468,7 @@ func (a *App) uploadFile(ctx context.Context, path string, c *tinypng.Client) (r
 
 			return nil
 		},
-		retry.WithDelayBetweenAttempts(a.opt.DelayBetweenRetries),
-		retry.WithStopOnError(tinypng.ErrUnauthorized, tinypng.ErrTooManyRequests),
+		a.apiRetryOptions()...,
 	)
 }
 
@@ -440,6 +1477,7 @@ func (a *App) downloadCompressed(
 	ctx context.Context,
 	comp *tinypng.Compressed,
 	path string,
+	extra ...tinypng.DownloadOption,
 ) error {
 	return retry.Try(
 		ctx,
@@ -452,17 +1490,206 @@ func (a *App) downloadCompressed(
 
 			defer func() { _ = f.Close() }()
 
-			var opts []tinypng.DownloadOption
+			return comp.Download(ctx, f, a.downloadOptions(extra...)...)
+		},
+		a.apiRetryOptions()...,
+	)
+}
 
-			if a.opt.PreserveTime {
-				opts = append(opts, tinypng.WithDownloadPreserveCreation())
-			}
+// storeCompressed stores the compressed file in the cloud storage (instead of downloading it) and returns the
+// URL of the stored file.
+func (a *App) storeCompressed(ctx context.Context, comp *tinypng.Compressed, origPath string) (string, error) {
+	var (
+		objectPath = expandStorePath(a.opt.Store.PathTemplate, origPath)
+		target     tinypng.StoreTarget
+		location   string
+	)
+
+	switch a.opt.Store.Service {
+	case storeServiceS3:
+		target = tinypng.S3Store{
+			AccessKeyID:     a.opt.Store.S3AccessKeyID,
+			SecretAccessKey: a.opt.Store.S3SecretAccessKey,
+			Region:          a.opt.Store.S3Region,
+			Path:            objectPath,
+		}
+	case storeServiceGCS:
+		target = tinypng.GCSStore{AccessToken: a.opt.Store.GCSAccessToken, Path: objectPath}
+	default:
+		return "", fmt.Errorf("unsupported store service %q", a.opt.Store.Service)
+	}
+
+	return location, retry.Try(
+		ctx,
+		a.opt.RetryAttempts,
+		func(context.Context, uint) (err error) {
+			location, err = comp.Store(ctx, target, a.downloadOptions()...)
 
-			return comp.Download(ctx, f, opts...)
+			return
 		},
-		retry.WithDelayBetweenAttempts(a.opt.DelayBetweenRetries),
-		retry.WithStopOnError(tinypng.ErrUnauthorized, tinypng.ErrTooManyRequests),
+		a.apiRetryOptions()...,
+	)
+}
+
+// expandStorePath replaces the placeholders in the store path template with the values related to the file:
+//   - {name} - file name with the extension (e.g. "image.png")
+//   - {base} - file name without the extension (e.g. "image")
+//   - {ext} - file extension without the leading dot (e.g. "png")
+//   - {dir} - name of the directory containing the file (e.g. "images")
+func expandStorePath(template, filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil { // so the {dir} of "image.png" is the real directory name
+		filePath = abs
+	}
+
+	var (
+		name = filepath.Base(filePath)
+		ext  = filepath.Ext(name)
+	)
+
+	return strings.NewReplacer(
+		"{name}", name,
+		"{base}", strings.TrimSuffix(name, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{dir}", filepath.Base(filepath.Dir(filePath)),
+	).Replace(template)
+}
+
+// errOutputExists is returned when the output file already exists and should not be overwritten.
+var errOutputExists = errors.New("output file already exists")
+
+// absPaths returns the absolute (and cleaned) versions of the given paths. Paths that cannot be resolved are
+// returned as is.
+func absPaths(paths []string) []string {
+	var out = make([]string, len(paths))
+
+	for i, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			out[i] = abs
+		} else {
+			out[i] = filepath.Clean(p)
+		}
+	}
+
+	return out
+}
+
+// relativeToRoots returns the path of the file relative to the (longest) input root it was found in. For the
+// files given directly (not found in a directory), only the file name is returned.
+func relativeToRoots(roots []string, path string) string {
+	var rel string
+
+	for _, root := range roots {
+		if root == path {
+			continue // the file itself is a root
+		}
+
+		r, err := filepath.Rel(root, path)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue // the file is outside the root
+		}
+
+		if rel == "" || len(r) < len(rel) { // the shorter relative path - the longer (closer) root
+			rel = r
+		}
+	}
+
+	if rel == "" {
+		return filepath.Base(path)
+	}
+
+	return rel
+}
+
+// outputPaths returns the paths of the files written to the output directory for the given input file: the
+// compressed file itself, or its converted versions in conversion mode.
+func (a *App) outputPaths(roots []string, path string) []string {
+	var outPath = filepath.Join(a.opt.OutputDir, relativeToRoots(roots, path))
+
+	if len(a.opt.ConvertTo) == 0 {
+		return []string{outPath}
+	}
+
+	var (
+		origMime, _ = convertMimeType(strings.TrimPrefix(filepath.Ext(path), "."))
+		out         = make([]string, 0, len(a.opt.ConvertTo))
 	)
+
+	for _, format := range a.opt.ConvertTo {
+		if mimeType, _ := convertMimeType(format); strings.EqualFold(mimeType, origMime) {
+			continue // the conversion to the same format is skipped
+		}
+
+		out = append(out, convertedPath(outPath, format))
+	}
+
+	return out
+}
+
+// claimOutputs marks the output paths of the input file as taken. If any of them is already taken by another
+// input file (e.g. the files with the same relative path in different roots), an error is returned and nothing
+// is claimed, so the output file is never silently overwritten.
+func claimOutputs(claimed map[string]string, outPaths []string, path string) error {
+	for _, outPath := range outPaths {
+		if other, taken := claimed[outPath]; taken && other != path {
+			return fmt.Errorf("output path collision (%s): %s is already written from %s",
+				filepath.Base(path), outPath, other,
+			)
+		}
+	}
+
+	for _, outPath := range outPaths {
+		claimed[outPath] = path
+	}
+
+	return nil
+}
+
+// convertedPath returns the path of the file converted to the given format (the extension is replaced).
+func convertedPath(path, format string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + strings.ToLower(format)
+}
+
+// moveToOutput moves the compressed (temporary) file to the output path. The existing output file is overwritten,
+// unless the "skip" policy is set (errOutputExists is returned then, e.g. if the file appeared during the upload).
+func (a *App) moveToOutput(origStat os.FileInfo, tmpPath, outPath string) error {
+	if a.opt.OutputExists == outputExistsSkip {
+		if _, err := os.Stat(outPath); err == nil {
+			return errOutputExists
+		}
+	}
+
+	if err := os.Chmod(tmpPath, origStat.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return err
+	}
+
+	if a.opt.PreserveTime {
+		_ = os.Chtimes(outPath, origStat.ModTime(), origStat.ModTime())
+	}
+
+	return nil
+}
+
+// downloadOptions returns the download options based on the application options, with the extra ones appended.
+func (a *App) downloadOptions(extra ...tinypng.DownloadOption) []tinypng.DownloadOption {
+	var opts = make([]tinypng.DownloadOption, 0, 2+len(extra)) //nolint:mnd
+
+	if a.opt.PreserveTime {
+		opts = append(opts, tinypng.WithDownloadPreserveCreation())
+	}
+
+	if a.opt.ResizeMethod != "" {
+		opts = append(opts, tinypng.WithDownloadResize(
+			tinypng.ResizeMethod(a.opt.ResizeMethod),
+			uint32(a.opt.ResizeWidth),  //nolint:gosec
+			uint32(a.opt.ResizeHeight), //nolint:gosec
+		))
+	}
+
+	return append(opts, extra...)
 }
 
 // Step 3 is replaceFiles - it replaces the original file content with the compressed one.
@@ -534,20 +1761,133 @@ func (a *App) replaceFiles(ctx context.Context, origPath, compPath string) error
 
 			return nil
 		},
-		retry.WithDelayBetweenAttempts(a.opt.DelayBetweenRetries),
+		a.retryOptions()...,
 	)
 }
 
+// convertFile downloads the compressed file converted to the given format and saves it next to the original
+// file (with the corresponding extension). It returns the path to the converted file and its size.
+func (a *App) convertFile(
+	ctx context.Context,
+	basePath string,
+	origStat os.FileInfo,
+	comp *tinypng.Compressed,
+	format string,
+) (string, uint64, error) {
+	mimeType, ok := convertMimeType(format)
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported format %q", format)
+	}
+
+	var (
+		outPath     = convertedPath(basePath, format)
+		tmpFilePath = outPath + ".tiny"
+		opts        = []tinypng.DownloadOption{tinypng.WithDownloadConvert(mimeType)}
+	)
+
+	if a.opt.OutputDir != "" {
+		if _, err := os.Stat(outPath); err == nil && a.opt.OutputExists == outputExistsSkip {
+			return "", 0, errOutputExists
+		}
+
+		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil { //nolint:mnd
+			return "", 0, err
+		}
+	}
+
+	if a.opt.ConvertBackground != "" {
+		opts = append(opts, tinypng.WithDownloadBackground(a.opt.ConvertBackground))
+	}
+
+	defer func() { // remove the temporary file if it exists
+		if _, tmpStatErr := os.Stat(tmpFilePath); tmpStatErr == nil {
+			_ = os.Remove(tmpFilePath)
+		}
+	}()
+
+	if err := a.downloadCompressed(ctx, comp, tmpFilePath, opts...); err != nil {
+		return "", 0, err
+	}
+
+	if err := os.Rename(tmpFilePath, outPath); err != nil {
+		return "", 0, err
+	}
+
+	if a.opt.PreserveTime {
+		_ = os.Chtimes(outPath, origStat.ModTime(), origStat.ModTime())
+	}
+
+	outStat, err := os.Stat(outPath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return outPath, uint64(outStat.Size()), nil //nolint:gosec
+}
+
+// detectImageType detects the image type (MIME) by the file content. An error is returned if the file is not
+// a supported image, or its extension (if it's a known image one) does not match the content.
+func detectImageType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	mimeType, err := validate.ImageType(f)
+	if err != nil {
+		return "", err
+	}
+
+	if mimeType == "" {
+		return "", errors.New("the content is not a supported image")
+	}
+
+	if extMime, ok := convertMimeType(strings.TrimPrefix(filepath.Ext(path), ".")); ok && extMime != mimeType {
+		return "", fmt.Errorf("the file extension does not match its content (%s)", mimeType)
+	}
+
+	return mimeType, nil
+}
+
+// convertMimeType returns the MIME type for the given conversion format (file extension).
+func convertMimeType(format string) (string, bool) {
+	switch strings.ToLower(format) {
+	case "webp":
+		return "image/webp", true
+	case "avif":
+		return "image/avif", true
+	case "png":
+		return "image/png", true
+	case "jpg", "jpeg":
+		return "image/jpeg", true
+	}
+
+	return "", false
+}
+
 func (a *App) logf(format string, args ...any) {
 	a.logMu.Lock()
 	defer a.logMu.Unlock()
 
-	_, _ = fmt.Fprintf(os.Stdout, format+"\n", args...)
+	_, _ = fmt.Fprintf(a.logOutput(), format+"\n", args...)
+}
+
+// logOutput returns the writer for the log messages. When the run report is written to the stdout, the logs
+// are moved to the stderr to keep the report parseable.
+func (a *App) logOutput() io.Writer {
+	if (a.opt.ReportFormat != "" && (a.opt.ReportFile == "" || a.opt.ReportFile == reportToStdout)) ||
+		a.opt.JUnitReportFile == reportToStdout {
+		return a.stderr
+	}
+
+	return a.stdout
 }
 
 func (a *App) errorf(format string, args ...any) {
 	a.logMu.Lock()
 	defer a.logMu.Unlock()
 
-	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+	_, _ = fmt.Fprintf(a.stderr, format+"\n", args...)
 }
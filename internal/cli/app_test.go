@@ -0,0 +1,928 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
+)
+
+func TestApp_Run(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv     = tinypngtest.NewServer(tinypngtest.WithAPIKeys("valid-key"))
+		tmpDir  = t.TempDir()
+		imgsDir = filepath.Join(tmpDir, "images")
+		report  = filepath.Join(tmpDir, "report.json")
+	)
+
+	t.Cleanup(srv.Close)
+
+	var sizes = map[string]int64{
+		filepath.Join(imgsDir, "a.png"):        writeImage(t, filepath.Join(imgsDir, "a.png"), 1),
+		filepath.Join(imgsDir, "sub", "b.png"): writeImage(t, filepath.Join(imgsDir, "sub", "b.png"), 2),
+	}
+
+	writeFile(t, filepath.Join(imgsDir, "c.txt"), "not an image")
+
+	assertNoError(t, runApp(t,
+		"--api-key", "valid-key",
+		"--api-url", srv.URL,
+		"--recursive",
+		"--no-cache",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		imgsDir,
+	))
+
+	// the original files are replaced with the compressed ones
+	for path, origSize := range sizes {
+		stat, err := os.Stat(path)
+		assertNoError(t, err)
+
+		if stat.Size() >= origSize {
+			t.Errorf("file %s is not compressed: %d >= %d", path, stat.Size(), origSize)
+		}
+	}
+
+	assertEqual(t, uint64(2), srv.UsedQuota("valid-key"))
+
+	// the stats are written to the report
+	var got struct {
+		Files  []reportFile `json:"files"`
+		Totals reportTotals `json:"totals"`
+	}
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+	assertNoError(t, json.Unmarshal(data, &got))
+
+	assertEqual(t, 2, got.Totals.Files)
+	assertEqual(t, 2, got.Totals.Compressed)
+	assertEqual(t, 0, got.Totals.Errors)
+	assertEqual(t, uint64(sizes[filepath.Join(imgsDir, "a.png")]+sizes[filepath.Join(imgsDir, "sub", "b.png")]),
+		got.Totals.OriginalSize,
+	)
+
+	for _, f := range got.Files {
+		assertEqual(t, statusCompressed, f.Status)
+		assertEqual(t, "image/png", f.Type)
+		assertEqual(t, "vali…-key", f.Key)
+
+		if stat, statErr := os.Stat(f.Path); statErr == nil {
+			assertEqual(t, uint64(stat.Size()), f.CompressedSize) //nolint:gosec
+		} else {
+			t.Errorf("unexpected file in the report: %s", f.Path)
+		}
+	}
+}
+
+func TestApp_Run_Duplicates(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		report = filepath.Join(tmpDir, "report.json")
+		paths  = []string{
+			filepath.Join(tmpDir, "a.png"),
+			filepath.Join(tmpDir, "b.png"),
+			filepath.Join(tmpDir, "c.png"),
+			filepath.Join(tmpDir, "d.png"),
+		}
+	)
+
+	t.Cleanup(srv.Close)
+
+	writeImage(t, paths[0], 1)
+	writeImage(t, paths[1], 1) // the same content as a.png
+	writeImage(t, paths[2], 2)
+	writeImage(t, paths[3], 1) // the same content as a.png
+
+	assertNoError(t, runApp(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--no-cache",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		tmpDir,
+	))
+
+	assertEqual(t, uint64(2), srv.UsedQuota("any-key")) // the duplicates are not uploaded
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+
+	var got struct {
+		Files []reportFile `json:"files"`
+	}
+
+	assertNoError(t, json.Unmarshal(data, &got))
+	assertEqual(t, 4, len(got.Files))
+
+	var (
+		leaders    = make(map[string]struct{})
+		duplicates int
+	)
+
+	for _, f := range got.Files {
+		assertEqual(t, statusCompressed, f.Status)
+
+		if f.DuplicateOf != "" {
+			duplicates++
+			leaders[f.DuplicateOf] = struct{}{}
+		}
+
+		stat, statErr := os.Stat(f.Path)
+		assertNoError(t, statErr)
+		assertEqual(t, f.CompressedSize, uint64(stat.Size())) //nolint:gosec
+	}
+
+	assertEqual(t, 2, duplicates)
+	assertEqual(t, 1, len(leaders)) // both duplicates reuse the same leader
+}
+
+func TestApp_Run_ResizeIgnoresCache(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		path   = filepath.Join(tmpDir, "a.png")
+		cache  = filepath.Join(tmpDir, "tinifier.cache")
+		report = filepath.Join(tmpDir, "report.json")
+	)
+
+	t.Cleanup(srv.Close)
+
+	writeImage(t, path, 1)
+
+	// the plain compression puts the compressed file hash into the cache
+	assertNoError(t, runApp(t, "--api-key", "any-key", "--api-url", srv.URL, "--cache-file", cache, path))
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key"))
+
+	// so the same run again is short-circuited by the cache
+	assertNoError(t, runApp(t, "--api-key", "any-key", "--api-url", srv.URL, "--cache-file", cache, path))
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key"))
+
+	// but the resizing must not be
+	assertNoError(t, runApp(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--cache-file", cache,
+		"--resize-method", "scale",
+		"--resize-width", "10",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		path,
+	))
+
+	if used := srv.UsedQuota("any-key"); used <= 1 {
+		t.Errorf("the resize run did not use the API: %d compressions used", used)
+	}
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+
+	var got struct {
+		Files []reportFile `json:"files"`
+	}
+
+	assertNoError(t, json.Unmarshal(data, &got))
+	assertEqual(t, 1, len(got.Files))
+	assertEqual(t, statusCompressed, got.Files[0].Status)
+}
+
+func TestApp_Run_CacheKeyOptions(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer() // the compression ratio is 0.7, so ~30% is saved
+		tmpDir = t.TempDir()
+		path   = filepath.Join(tmpDir, "a.png")
+		cache  = filepath.Join(tmpDir, "tinifier.cache")
+	)
+
+	t.Cleanup(srv.Close)
+
+	writeImage(t, path, 1)
+
+	var run = func(skipIfDiffLess string) {
+		t.Helper()
+
+		assertNoError(t, runApp(t,
+			"--api-key", "any-key",
+			"--api-url", srv.URL,
+			"--cache-file", cache,
+			"--skip-if-diff-less", skipIfDiffLess,
+			path,
+		))
+	}
+
+	run("50") // not worth it, so the file is cached as incompressible
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key"))
+
+	run("50") // the same options - the cache is used
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key"))
+
+	run("10") // the other options - the cached result is not applicable
+	assertEqual(t, uint64(2), srv.UsedQuota("any-key"))
+}
+
+func TestApp_OpenCache(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveOptions func(*options)
+		wantCache   bool
+	}{
+		"in place":         {giveOptions: func(*options) {}, wantCache: true},
+		"disabled":         {giveOptions: func(o *options) { o.NoCache = true }},
+		"no file":          {giveOptions: func(o *options) { o.CacheFile = "" }},
+		"output directory": {giveOptions: func(o *options) { o.OutputDir = "out" }},
+		"resize":           {giveOptions: func(o *options) { o.ResizeMethod = "fit" }},
+		"convert":          {giveOptions: func(o *options) { o.ConvertTo = []string{"webp"} }},
+		"store":            {giveOptions: func(o *options) { o.Store.Service = storeServiceS3 }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var app = App{opt: newOptionsWithDefaults()}
+
+			app.opt.CacheFile = filepath.Join(t.TempDir(), "tinifier.cache")
+			tc.giveOptions(&app.opt)
+
+			c, err := app.openCache()
+			assertNoError(t, err)
+			assertEqual(t, tc.wantCache, c != nil)
+		})
+	}
+}
+
+func TestApp_Run_InvalidKeys(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer(tinypngtest.WithAPIKeys("valid-key"))
+		tmpDir = t.TempDir()
+		path   = filepath.Join(tmpDir, "a.png")
+		size   = writeImage(t, path, 1)
+		report = filepath.Join(tmpDir, "report.json")
+	)
+
+	t.Cleanup(srv.Close)
+
+	// the invalid keys are retired one by one, and then the process is stopped
+	assertNoError(t, runApp(t,
+		"--api-key", "bad-key-1,bad-key-2",
+		"--api-url", srv.URL,
+		"--no-cache",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		path,
+	))
+
+	stat, err := os.Stat(path)
+	assertNoError(t, err)
+	assertEqual(t, size, stat.Size())
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+
+	var got struct {
+		Files []reportFile `json:"files"`
+	}
+
+	assertNoError(t, json.Unmarshal(data, &got))
+	assertEqual(t, 1, len(got.Files))
+	assertEqual(t, statusError, got.Files[0].Status)
+	assertContains(t, got.Files[0].Error, tinypng.ErrNoClients.Error())
+}
+
+func TestApp_Run_DownloadRejectedKey(t *testing.T) {
+	t.Parallel()
+
+	const flakyKey = "flaky-key" // the key is accepted for the uploads, but rejected for the downloads
+
+	var (
+		fake   = tinypngtest.NewHandler()
+		tmpDir = t.TempDir()
+		report = filepath.Join(tmpDir, "report.json")
+		srv    = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, key, _ := r.BasicAuth(); key == flakyKey && strings.HasPrefix(r.URL.Path, "/output/") {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"Unauthorized","message":"Credentials are invalid."}`))
+
+				return
+			}
+
+			fake.ServeHTTP(w, r)
+		}))
+	)
+
+	t.Cleanup(srv.Close)
+
+	for i := range 3 {
+		writeImage(t, filepath.Join(tmpDir, "img", fmt.Sprintf("%d.png", i)), uint64(i)) //nolint:gosec
+	}
+
+	assertNoError(t, runApp(t,
+		"--api-key", flakyKey+",good-key",
+		"--api-url", srv.URL,
+		"--keys-strategy", "round-robin",
+		"--threads", "1",
+		"--no-cache",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		filepath.Join(tmpDir, "img"),
+	))
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+
+	var got struct {
+		Totals reportTotals `json:"totals"`
+	}
+
+	assertNoError(t, json.Unmarshal(data, &got))
+	assertEqual(t, 1, got.Totals.Errors)     // the key is retired after the first rejected download
+	assertEqual(t, 2, got.Totals.Compressed) // so the rest of the files are compressed using the good key
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveErr error
+		want    errorAction
+	}{
+		"unauthorized":      {giveErr: &tinypng.APIError{StatusCode: http.StatusUnauthorized}, want: errActionRotate},
+		"too many requests": {giveErr: &tinypng.APIError{StatusCode: http.StatusTooManyRequests}, want: errActionRotate},
+		"bad request":       {giveErr: &tinypng.APIError{StatusCode: http.StatusBadRequest}, want: errActionSkip},
+		"unsupported type":  {giveErr: &tinypng.APIError{StatusCode: http.StatusUnsupportedMediaType}, want: errActionSkip},
+		"request timeout":   {giveErr: &tinypng.APIError{StatusCode: http.StatusRequestTimeout}, want: errActionRetry},
+		"server error":      {giveErr: &tinypng.APIError{StatusCode: http.StatusBadGateway}, want: errActionRetry},
+		"wrapped":           {giveErr: fmt.Errorf("foo: %w", &tinypng.APIError{StatusCode: 401}), want: errActionRotate},
+		"canceled":          {giveErr: context.Canceled, want: errActionSkip},
+		"network":           {giveErr: errors.New("connection reset"), want: errActionRetry},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assertEqual(t, tc.want, classifyError(tc.giveErr))
+		})
+	}
+}
+
+func TestApp_DryRun(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		path   = filepath.Join(tmpDir, "a.png")
+		size   = writeImage(t, path, 1)
+		report = filepath.Join(tmpDir, "report.json")
+	)
+
+	t.Cleanup(srv.Close)
+
+	assertNoError(t, runApp(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--dry-run",
+		"--no-cache",
+		"--resize-method", "scale",
+		"--resize-width", "10",
+		"--report", reportFormatJSON,
+		"--report-file", report,
+		path,
+	))
+
+	stat, err := os.Stat(path)
+	assertNoError(t, err)
+	assertEqual(t, size, stat.Size()) // the file is untouched
+	assertEqual(t, uint64(0), srv.UsedQuota("any-key"))
+
+	data, err := os.ReadFile(report)
+	assertNoError(t, err)
+
+	var got struct {
+		Files []reportFile `json:"files"`
+	}
+
+	assertNoError(t, json.Unmarshal(data, &got))
+	assertEqual(t, 1, len(got.Files))
+	assertEqual(t, statusDryRun, got.Files[0].Status)
+}
+
+func TestApp_Run_Convert(t *testing.T) {
+	t.Parallel()
+
+	// readReport returns the files from the JSON report
+	var readReport = func(t *testing.T, path string) []reportFile {
+		t.Helper()
+
+		data, err := os.ReadFile(path)
+		assertNoError(t, err)
+
+		var got struct {
+			Files []reportFile `json:"files"`
+		}
+
+		assertNoError(t, json.Unmarshal(data, &got))
+
+		return got.Files
+	}
+
+	t.Run("sibling files", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			srv    = tinypngtest.NewServer()
+			tmpDir = t.TempDir()
+			path   = filepath.Join(tmpDir, "img", "a.png")
+			size   = writeImage(t, path, 1)
+			report = filepath.Join(tmpDir, "report.json")
+		)
+
+		t.Cleanup(srv.Close)
+
+		assertNoError(t, runApp(t,
+			"--api-key", "any-key",
+			"--api-url", srv.URL,
+			"--no-cache",
+			"--convert-to", "webp,png,avif",
+			"--report", reportFormatJSON,
+			"--report-file", report,
+			path,
+		))
+
+		for _, ext := range []string{"webp", "avif"} {
+			if _, err := os.Stat(filepath.Join(tmpDir, "img", "a."+ext)); err != nil {
+				t.Errorf("expected the converted file a.%s: %v", ext, err)
+			}
+		}
+
+		stat, err := os.Stat(path)
+		assertNoError(t, err)
+		assertEqual(t, size, stat.Size()) // the original file is untouched, the png to png conversion is skipped
+
+		var files = readReport(t, report) // one row per converted format
+
+		assertEqual(t, 2, len(files))
+		assertEqual(t, "image/webp", files[0].Type)
+		assertEqual(t, filepath.Join(tmpDir, "img", "a.webp"), files[0].Path)
+		assertEqual(t, "image/avif", files[1].Type)
+		assertEqual(t, filepath.Join(tmpDir, "img", "a.avif"), files[1].Path)
+
+		assertEqual(t, uint64(3), srv.UsedQuota("any-key")) // the upload and two conversions
+	})
+
+	t.Run("existing output is skipped", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			srv    = tinypngtest.NewServer()
+			tmpDir = t.TempDir()
+			outDir = filepath.Join(tmpDir, "out")
+			report = filepath.Join(tmpDir, "report.json")
+		)
+
+		t.Cleanup(srv.Close)
+
+		writeImage(t, filepath.Join(tmpDir, "img", "a.png"), 1)
+		writeFile(t, filepath.Join(outDir, "a.webp"), "already exists") // must be kept with the skip policy
+
+		assertNoError(t, runApp(t,
+			"--api-key", "any-key",
+			"--api-url", srv.URL,
+			"--no-cache",
+			"--convert-to", "webp,avif",
+			"--output-dir", outDir,
+			"--output-exists", outputExistsSkip,
+			"--report", reportFormatJSON,
+			"--report-file", report,
+			filepath.Join(tmpDir, "img"),
+		))
+
+		data, err := os.ReadFile(filepath.Join(outDir, "a.webp"))
+		assertNoError(t, err)
+		assertEqual(t, "already exists", string(data))
+
+		_, err = os.Stat(filepath.Join(outDir, "a.avif"))
+		assertNoError(t, err)
+
+		var files = readReport(t, report)
+
+		assertEqual(t, 2, len(files))
+		assertEqual(t, "image/webp", files[0].Type)
+		assertEqual(t, statusSkipped, files[0].Status)
+		assertEqual(t, "image/avif", files[1].Type)
+		assertEqual(t, statusCompressed, files[1].Status)
+
+		assertEqual(t, uint64(2), srv.UsedQuota("any-key")) // the upload and one conversion
+	})
+
+	t.Run("dry run estimate", func(t *testing.T) {
+		t.Parallel()
+
+		var path = filepath.Join(t.TempDir(), "a.png")
+
+		writeImage(t, path, 1)
+
+		out, err := runAppOutput(t, "--api-key", "any-key", "--dry-run", "--no-cache", "--convert-to", "png,webp", path)
+		assertNoError(t, err)
+
+		if !strings.Contains(out, "estimated API compressions: 2") { // the png to png conversion is not counted
+			t.Errorf("unexpected estimate in the output: %q", out)
+		}
+	})
+}
+
+func TestApp_Run_OutputDir(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		outDir = filepath.Join(tmpDir, "out")
+	)
+
+	t.Cleanup(srv.Close)
+
+	var (
+		rootA  = filepath.Join(tmpDir, "a")
+		rootB  = filepath.Join(tmpDir, "b")
+		direct = filepath.Join(tmpDir, "direct.png")
+	)
+
+	writeImage(t, filepath.Join(rootA, "1.png"), 1)
+	writeImage(t, filepath.Join(rootA, "sub", "2.png"), 2)
+	writeImage(t, filepath.Join(rootB, "3.png"), 3)
+	writeImage(t, direct, 4)
+
+	writeFile(t, filepath.Join(outDir, "3.png"), "already exists") // must be kept with the skip policy
+
+	assertNoError(t, runApp(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--no-cache",
+		"--recursive",
+		"--output-dir", outDir,
+		"--output-exists", outputExistsSkip,
+		rootA, rootB, direct,
+	))
+
+	for _, rel := range []string{"1.png", filepath.Join("sub", "2.png"), "direct.png"} {
+		if _, err := os.Stat(filepath.Join(outDir, rel)); err != nil {
+			t.Errorf("expected the output file %s: %v", rel, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "3.png"))
+	assertNoError(t, err)
+	assertEqual(t, "already exists", string(data))
+
+	assertEqual(t, uint64(3), srv.UsedQuota("any-key")) // the existing output is skipped before the upload
+}
+
+func TestApp_Run_OutputDir_Collision(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		outDir = filepath.Join(tmpDir, "out")
+		rootA  = filepath.Join(tmpDir, "dirA")
+		rootB  = filepath.Join(tmpDir, "dirB")
+	)
+
+	t.Cleanup(srv.Close)
+
+	writeImage(t, filepath.Join(rootA, "logo.png"), 1)
+	writeImage(t, filepath.Join(rootB, "logo.png"), 2) // the same relative path in another root
+
+	assertNoError(t, runApp(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--no-cache",
+		"--threads", "4",
+		"--output-dir", outDir,
+		rootA, rootB,
+	))
+
+	orig, err := os.ReadFile(filepath.Join(rootA, "logo.png"))
+	assertNoError(t, err)
+
+	out, err := os.ReadFile(filepath.Join(outDir, "logo.png"))
+	assertNoError(t, err)
+
+	if !bytes.HasPrefix(orig, out) { // the fake server truncates the data, so the output is a prefix of the input
+		t.Error("the output file must be written from the first root")
+	}
+
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key")) // the colliding file is not uploaded
+}
+
+func TestRelativeToRoots(t *testing.T) {
+	t.Parallel()
+
+	var sep = string(filepath.Separator)
+
+	for name, tc := range map[string]struct {
+		giveRoots []string
+		givePath  string
+		want      string
+	}{
+		"file in the root":           {giveRoots: []string{"/img"}, givePath: "/img/a.png", want: "a.png"},
+		"file in the subdirectory":   {giveRoots: []string{"/img"}, givePath: "/img/sub/a.png", want: "sub" + sep + "a.png"},
+		"file passed directly":       {giveRoots: []string{"/img/a.png"}, givePath: "/img/a.png", want: "a.png"},
+		"file outside the roots":     {giveRoots: []string{"/img"}, givePath: "/other/a.png", want: "a.png"},
+		"sibling with common prefix": {giveRoots: []string{"/img"}, givePath: "/img2/a.png", want: "a.png"},
+		"several roots": {
+			giveRoots: []string{"/foo", "/img", "/bar"},
+			givePath:  "/img/sub/a.png",
+			want:      "sub" + sep + "a.png",
+		},
+		"nested roots, the closest wins": {
+			giveRoots: []string{"/img", "/img/sub"},
+			givePath:  "/img/sub/deep/a.png",
+			want:      "deep" + sep + "a.png",
+		},
+		"direct file and its directory": {
+			giveRoots: []string{"/img/sub/a.png", "/img"},
+			givePath:  "/img/sub/a.png",
+			want:      "sub" + sep + "a.png",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var roots = make([]string, len(tc.giveRoots))
+
+			for i, root := range tc.giveRoots {
+				roots[i] = filepath.FromSlash(root)
+			}
+
+			assertEqual(t, tc.want, relativeToRoots(roots, filepath.FromSlash(tc.givePath)))
+		})
+	}
+}
+
+func TestApp_MoveToOutput(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		givePolicy   string
+		giveExisting bool
+		wantErr      error
+		wantContent  string
+	}{
+		"overwrite, no output":       {givePolicy: outputExistsOverwrite, wantContent: "compressed"},
+		"overwrite, existing output": {givePolicy: outputExistsOverwrite, giveExisting: true, wantContent: "compressed"},
+		"skip, no output":            {givePolicy: outputExistsSkip, wantContent: "compressed"},
+		"skip, existing output": {
+			givePolicy:   outputExistsSkip,
+			giveExisting: true,
+			wantErr:      errOutputExists,
+			wantContent:  "existing",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				tmpDir  = t.TempDir()
+				orig    = filepath.Join(tmpDir, "orig.png")
+				tmp     = filepath.Join(tmpDir, "orig.png.tiny")
+				out     = filepath.Join(tmpDir, "out", "orig.png")
+				modTime = time.Now().Add(-time.Hour).Truncate(time.Second)
+				app     = App{opt: newOptionsWithDefaults()}
+			)
+
+			app.opt.OutputExists, app.opt.PreserveTime = tc.givePolicy, true
+
+			writeFile(t, orig, "original")
+			assertNoError(t, os.Chmod(orig, 0o640))
+			assertNoError(t, os.Chtimes(orig, modTime, modTime))
+			writeFile(t, tmp, "compressed")
+
+			if tc.giveExisting {
+				writeFile(t, out, "existing")
+			} else {
+				assertNoError(t, os.MkdirAll(filepath.Dir(out), 0o755))
+			}
+
+			origStat, err := os.Stat(orig)
+			assertNoError(t, err)
+
+			err = app.moveToOutput(origStat, tmp, out)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+			} else {
+				assertNoError(t, err)
+
+				outStat, statErr := os.Stat(out)
+				assertNoError(t, statErr)
+				assertEqual(t, os.FileMode(0o640), outStat.Mode().Perm())
+				assertEqual(t, modTime.Unix(), outStat.ModTime().Unix())
+			}
+
+			data, err := os.ReadFile(out)
+			assertNoError(t, err)
+			assertEqual(t, tc.wantContent, string(data))
+
+			data, err = os.ReadFile(orig)
+			assertNoError(t, err)
+			assertEqual(t, "original", string(data)) // the original file stays untouched
+		})
+	}
+}
+
+func TestApp_Run_Store(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv    = tinypngtest.NewServer()
+		tmpDir = t.TempDir()
+		path   = filepath.Join(tmpDir, "photos", "cat.png")
+		size   = writeImage(t, path, 1)
+	)
+
+	t.Cleanup(srv.Close)
+
+	stdout, err := runAppOutput(t,
+		"--api-key", "any-key",
+		"--api-url", srv.URL,
+		"--no-cache",
+		"--store-service", storeServiceS3,
+		"--store-path", "bucket/{dir}/{base}.min.{ext}",
+		"--store-s3-access-key-id", "id",
+		"--store-s3-secret-access-key", "secret",
+		"--store-s3-region", "us-west-1",
+		path,
+	)
+	assertNoError(t, err)
+
+	if want := "https://s3-us-west-1.amazonaws.com/bucket/photos/cat.min.png"; !strings.Contains(stdout, want) {
+		t.Errorf("expected the stored location %s in the output, got %q", want, stdout)
+	}
+
+	stat, err := os.Stat(path)
+	assertNoError(t, err)
+	assertEqual(t, size, stat.Size()) // the original file stays untouched
+
+	assertEqual(t, uint64(1), srv.UsedQuota("any-key")) // storing is not an additional compression
+}
+
+func TestExpandStorePath(t *testing.T) {
+	t.Parallel()
+
+	cwd, err := os.Getwd()
+	assertNoError(t, err)
+
+	for name, tc := range map[string]struct {
+		giveTemplate string
+		givePath     string
+		want         string
+	}{
+		"all placeholders": {
+			giveTemplate: "bucket/{dir}/{base}.{ext}|{name}",
+			givePath:     "/img/photo.png",
+			want:         "bucket/img/photo.png|photo.png",
+		},
+		"nested path": {
+			giveTemplate: "bucket/{dir}/{name}",
+			givePath:     "/assets/icons/social/logo.png",
+			want:         "bucket/social/logo.png",
+		},
+		"nested relative path": {
+			giveTemplate: "bucket/{dir}/{name}",
+			givePath:     filepath.Join("icons", "social", "logo.png"),
+			want:         "bucket/social/logo.png",
+		},
+		"relative path without directory": {
+			giveTemplate: "bucket/{dir}/{name}",
+			givePath:     "logo.png",
+			want:         "bucket/" + filepath.Base(cwd) + "/logo.png",
+		},
+		"several dots": {
+			giveTemplate: "bucket/{base}.{ext}",
+			givePath:     "/img/photo.min.jpeg",
+			want:         "bucket/photo.min.jpeg",
+		},
+		"extension is kept as is": {
+			giveTemplate: "bucket/{ext}/{base}",
+			givePath:     "/img/PHOTO.JPG",
+			want:         "bucket/JPG/PHOTO",
+		},
+		"no extension": {
+			giveTemplate: "bucket/{base}.{ext}",
+			givePath:     "/img/blob",
+			want:         "bucket/blob.",
+		},
+		"repeated placeholders": {
+			giveTemplate: "bucket/{base}/{base}.webp",
+			givePath:     "/img/photo.png",
+			want:         "bucket/photo/photo.webp",
+		},
+		"no placeholders": {
+			giveTemplate: "bucket/static.png",
+			givePath:     "/img/photo.png",
+			want:         "bucket/static.png",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assertEqual(t, tc.want, expandStorePath(tc.giveTemplate, tc.givePath))
+		})
+	}
+}
+
+// runApp runs the application with the given arguments, ignoring the configuration file in the home directory.
+func runApp(t *testing.T, args ...string) error {
+	t.Helper()
+
+	var config = filepath.Join(t.TempDir(), "missing-config.yml")
+
+	return NewApp("tinifier").Run(t.Context(), append([]string{"--config-file", config}, args...))
+}
+
+// runAppOutput is the same as runApp, but returns the standard output of the application (the stderr is
+// discarded).
+func runAppOutput(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var (
+		app    = NewApp("tinifier")
+		stdout bytes.Buffer
+		config = filepath.Join(t.TempDir(), "missing-config.yml")
+	)
+
+	app.stdout, app.stderr = &stdout, io.Discard
+
+	var err = app.Run(t.Context(), append([]string{"--config-file", config}, args...))
+
+	return stdout.String(), err
+}
+
+// writeImage writes the PNG image with the random noise (so it can't be compressed by the PNG encoder itself),
+// and returns its size. The seed makes the images different.
+func writeImage(t *testing.T, path string, seed uint64) int64 {
+	t.Helper()
+
+	var (
+		img = image.NewRGBA(image.Rect(0, 0, 32, 32))
+		rnd = rand.New(rand.NewPCG(seed, seed)) //nolint:gosec
+	)
+
+	for x := range 32 {
+		for y := range 32 {
+			img.Set(x, y, color.RGBA{R: uint8(rnd.UintN(256)), G: uint8(rnd.UintN(256)), B: 0, A: 255}) //nolint:gosec
+		}
+	}
+
+	assertNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+
+	f, err := os.Create(path)
+	assertNoError(t, err)
+
+	defer func() { _ = f.Close() }()
+
+	assertNoError(t, png.Encode(f, img))
+
+	stat, err := f.Stat()
+	assertNoError(t, err)
+
+	return stat.Size()
+}
+
+// writeFile writes the file with the given content, creating the parent directories.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	assertNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assertNoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
@@ -14,25 +14,123 @@ import (
 
 // Command represents a CLI command with flags, description, usage, and an action function.
 type Command struct {
-	Name        string    // Name of the command.
-	Description string    // Brief description of the command.
-	Usage       string    // Usage example of the command.
-	Version     string    // Version of the command.
-	Flags       []Flagger // Collection of flags associated with the command.
-	Output      io.Writer // Output writer, defaults to os.Stdout if not set.
+	Name        string     // Name of the command.
+	Description string     // Brief description of the command.
+	Usage       string     // Usage example of the command.
+	Version     string     // Version of the command.
+	Flags       []Flagger  // Collection of flags associated with the command.
+	GlobalFlags []Flagger  // Flags of the command, inherited by all the subcommands.
+	Commands    []*Command // Subcommands (the first non-flag argument is used as the subcommand name).
+	Hidden      bool       // Do not list the command in the parent command help.
+	Output      io.Writer  // Output writer, defaults to os.Stdout (or the parent command output) if not set.
 
 	Action func(_ context.Context, _ *Command, args []string) error // Action function executed when the command runs.
 
+	parent                *Command  // parent command (set on the parent initialization)
 	initOnce              sync.Once // to ensure initialization is done only once
 	showHelp, showVersion bool      // built-in flags for displaying help and version
 }
 
+// flagRegisterer is implemented by flags that can be registered in the flag set without resetting their values
+// (used for the inherited global flags, which are already applied and possibly set by the parent command).
+type flagRegisterer interface{ register(*flag.FlagSet) }
+
+// flagNamer is implemented by flags that expose their names (used to detect the shadowed inherited flags).
+type flagNamer interface{ names() []string }
+
+// ownFlags returns the global and regular flags of the command.
+func (c *Command) ownFlags() []Flagger {
+	return append(append(make([]Flagger, 0, len(c.GlobalFlags)+len(c.Flags)), c.GlobalFlags...), c.Flags...)
+}
+
+// fullName returns the command name prefixed with the names of all the parent commands.
+func (c *Command) fullName() string {
+	if c.parent != nil && c.parent.Name != "" {
+		return c.parent.fullName() + " " + c.Name
+	}
+
+	return c.Name
+}
+
+// inheritedFlags returns the global flags of all the parent commands (the closest parent goes first). The flags
+// with a name that is already used by the command itself (or by a closer parent) are shadowed, and skipped.
+func (c *Command) inheritedFlags() []Flagger {
+	var (
+		flags []Flagger
+		taken = make(map[string]struct{})
+	)
+
+	// shadowed reports whether any name of the flag is already taken, and marks its names as taken otherwise
+	var shadowed = func(f Flagger) bool {
+		n, ok := f.(flagNamer)
+		if !ok {
+			return false
+		}
+
+		for _, name := range n.names() {
+			if _, found := taken[name]; found {
+				return true
+			}
+		}
+
+		for _, name := range n.names() {
+			taken[name] = struct{}{}
+		}
+
+		return false
+	}
+
+	for _, f := range c.ownFlags() {
+		_ = shadowed(f)
+	}
+
+	for p := c.parent; p != nil; p = p.parent {
+		for _, f := range p.GlobalFlags {
+			if !shadowed(f) {
+				flags = append(flags, f)
+			}
+		}
+	}
+
+	return flags
+}
+
+// visibleCommands returns the subcommands that are not hidden.
+func (c *Command) visibleCommands() []*Command {
+	var cmds = make([]*Command, 0, len(c.Commands))
+
+	for _, sub := range c.Commands {
+		if sub != nil && !sub.Hidden {
+			cmds = append(cmds, sub)
+		}
+	}
+
+	return cmds
+}
+
+// subcommand returns the subcommand with the given name, or nil if not found.
+func (c *Command) subcommand(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub != nil && sub.Name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
 func (c *Command) init() {
 	c.initOnce.Do(func() {
 		c.Flags = append(c.Flags, // append built-in flags
 			&Flag[bool]{Names: []string{"help", "h"}, Usage: "Show help", Value: &c.showHelp},
 			&Flag[bool]{Names: []string{"version", "v"}, Usage: "Print the version", Value: &c.showVersion},
 		)
+
+		for _, sub := range c.Commands {
+			if sub != nil {
+				sub.parent = c
+			}
+		}
 	})
 }
 
@@ -61,7 +159,7 @@ func (c *Command) Help() string { //nolint:funlen
 
 		b.WriteString("Usage:\n")
 		b.WriteString(offset)
-		b.WriteString(c.Name)
+		b.WriteString(c.fullName())
 
 		if c.Usage != "" {
 			b.WriteRune(' ')
@@ -80,48 +178,85 @@ func (c *Command) Help() string { //nolint:funlen
 		b.WriteString(c.Version)
 	}
 
+	// append subcommands if any exist
+	if cmds := c.visibleCommands(); len(cmds) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+
+		b.WriteString("Commands:\n")
+
+		var names, descriptions = make([]string, len(cmds)), make([]string, len(cmds))
+
+		for i, sub := range cmds {
+			names[i], descriptions[i] = sub.Name, sub.Description
+		}
+
+		writeAligned(&b, offset, names, descriptions)
+	}
+
 	// append flags if any exist
-	if len(c.Flags) > 0 {
+	if flags := c.ownFlags(); len(flags) > 0 {
 		if b.Len() > 0 {
 			b.WriteString("\n\n")
 		}
 
 		b.WriteString("Options:\n")
 
-		var (
-			longest               int // stores the length of the longest flag name for alignment
-			flagNames, flagUsages = make([]string, len(c.Flags)), make([]string, len(c.Flags))
-		)
-
-		// iterate through flags to determine the longest name
-		for i, f := range c.Flags {
-			flagNames[i], flagUsages[i] = f.Help()
+		writeFlags(&b, offset, flags)
+	}
 
-			if l := utf8.RuneCountInString(flagNames[i]); l > longest {
-				longest = l
-			}
+	// append the flags inherited from the parent commands
+	if inherited := c.inheritedFlags(); len(inherited) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
 		}
 
-		// append flag information to the help message
-		for i, flagName := range flagNames {
-			if i > 0 {
-				b.WriteRune('\n')
-			}
+		b.WriteString("Global options:\n")
 
-			b.WriteString(offset)
-			b.WriteString(flagName)
+		writeFlags(&b, offset, inherited)
+	}
 
-			// align flag descriptions
-			for j := utf8.RuneCountInString(flagName); j < longest; j++ {
-				b.WriteRune(' ')
-			}
+	return b.String()
+}
+
+// writeFlags writes the flag names and usages, aligned in two columns.
+func writeFlags(b *strings.Builder, offset string, flags []Flagger) {
+	var flagNames, flagUsages = make([]string, len(flags)), make([]string, len(flags))
 
-			b.WriteString("  ")
-			b.WriteString(flagUsages[i])
+	for i, f := range flags {
+		flagNames[i], flagUsages[i] = f.Help()
+	}
+
+	writeAligned(b, offset, flagNames, flagUsages)
+}
+
+// writeAligned writes the names and descriptions in two columns, aligned by the longest name.
+func writeAligned(b *strings.Builder, offset string, names, descriptions []string) {
+	var longest int // stores the length of the longest name for alignment
+
+	for _, name := range names {
+		if l := utf8.RuneCountInString(name); l > longest {
+			longest = l
 		}
 	}
 
-	return b.String()
+	for i, name := range names {
+		if i > 0 {
+			b.WriteRune('\n')
+		}
+
+		b.WriteString(offset)
+		b.WriteString(name)
+
+		// align descriptions
+		for j := utf8.RuneCountInString(name); j < longest; j++ {
+			b.WriteRune(' ')
+		}
+
+		b.WriteString("  ")
+		b.WriteString(descriptions[i])
+	}
 }
 
 // Run executes the command with the provided arguments.
@@ -142,14 +277,29 @@ func (c *Command) Run(ctx context.Context, args []string) error { //nolint:funle
 
 	// set default output if not defined
 	if c.Output == nil {
-		c.Output = os.Stdout
+		if c.parent != nil && c.parent.Output != nil {
+			c.Output = c.parent.Output
+		} else {
+			c.Output = os.Stdout
+		}
 	}
 
+	var inherited = c.inheritedFlags()
+
 	// register flags in the flag set
-	for _, f := range c.Flags {
+	for _, f := range c.ownFlags() {
 		f.Apply(set)
 	}
 
+	// inherited flags are already applied by the parent command, so they must keep their values
+	for _, f := range inherited {
+		if r, ok := f.(flagRegisterer); ok {
+			r.register(set)
+		} else {
+			f.Apply(set)
+		}
+	}
+
 	// parse command-line arguments
 	if err := set.Parse(args); err != nil {
 		// display help message in case of a parsing error
@@ -185,8 +335,16 @@ func (c *Command) Run(ctx context.Context, args []string) error { //nolint:funle
 		return err
 	}
 
+	// dispatch to the subcommand, if the first argument is its name (the subcommand validates the flags
+	// and runs their actions by itself)
+	if set.NArg() > 0 {
+		if sub := c.subcommand(set.Arg(0)); sub != nil {
+			return sub.Run(ctx, set.Args()[1:])
+		}
+	}
+
 	// validate and execute any flag-specific actions
-	for _, f := range c.Flags {
+	for _, f := range append(c.ownFlags(), inherited...) {
 		if !f.IsSet() {
 			continue
 		}
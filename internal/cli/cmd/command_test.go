@@ -297,3 +297,146 @@ func TestCommand_Run(t *testing.T) {
 		assertEqual(t, executed, true)
 	})
 }
+
+func TestCommand_Subcommands(t *testing.T) {
+	t.Parallel()
+
+	var ctx = context.Background()
+
+	// newRoot creates a root command with the global flag, a regular subcommand and a hidden one
+	var newRoot = func(out *strings.Builder) (root, sub *cmd.Command, global *string, subFlag *bool, called *string) {
+		var (
+			globalValue  string
+			subFlagValue bool
+			calledName   string
+		)
+
+		sub = &cmd.Command{
+			Name:        "sub",
+			Description: "Some subcommand",
+			Flags:       []cmd.Flagger{&cmd.Flag[bool]{Names: []string{"sub-flag"}, Value: &subFlagValue}},
+			Action: func(_ context.Context, c *cmd.Command, _ []string) error {
+				calledName = c.Name
+
+				return nil
+			},
+		}
+
+		root = &cmd.Command{
+			Name:        "root",
+			Output:      out,
+			GlobalFlags: []cmd.Flagger{&cmd.Flag[string]{Names: []string{"global", "g"}, Value: &globalValue}},
+			Commands: []*cmd.Command{
+				sub,
+				{Name: "hidden", Description: "Hidden command", Hidden: true},
+			},
+			Action: func(_ context.Context, c *cmd.Command, _ []string) error {
+				calledName = c.Name
+
+				return nil
+			},
+		}
+
+		return root, sub, &globalValue, &subFlagValue, &calledName
+	}
+
+	t.Run("help", func(t *testing.T) {
+		t.Parallel()
+
+		var root, sub, _, _, _ = newRoot(new(strings.Builder))
+
+		assertEqual(t, root.Help(), `Usage:
+   root
+
+Commands:
+   sub  Some subcommand
+
+Options:
+   --global="…", -g="…"  
+   --help, -h            Show help
+   --version, -v         Print the version`)
+
+		// the subcommand help contains the full name and the flags inherited from the parent
+		assertContains(t, sub.Help(), "Usage:\n   root sub\n", `Options:
+   --sub-flag     
+   --help, -h     Show help
+   --version, -v  Print the version
+
+Global options:
+   --global="…", -g="…"  `)
+	})
+
+	t.Run("dispatch", func(t *testing.T) {
+		t.Parallel()
+
+		for name, tc := range map[string]struct {
+			giveArgs    []string
+			wantCalled  string
+			wantGlobal  string
+			wantSubFlag bool
+		}{
+			"root":                         {giveArgs: []string{"-g", "foo"}, wantCalled: "root", wantGlobal: "foo"},
+			"root with unknown arg":        {giveArgs: []string{"other"}, wantCalled: "root"},
+			"sub":                          {giveArgs: []string{"sub", "--sub-flag"}, wantCalled: "sub", wantSubFlag: true},
+			"sub, global before":           {giveArgs: []string{"-g", "foo", "sub"}, wantCalled: "sub", wantGlobal: "foo"},
+			"sub, global after":            {giveArgs: []string{"sub", "--global=bar"}, wantCalled: "sub", wantGlobal: "bar"},
+			"sub, global before and after": {giveArgs: []string{"-g", "foo", "sub", "-g", "bar"}, wantCalled: "sub", wantGlobal: "bar"},
+			"hidden":                       {giveArgs: []string{"hidden"}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				var root, _, global, subFlag, called = newRoot(new(strings.Builder))
+
+				assertNoError(t, root.Run(ctx, tc.giveArgs))
+				assertEqual(t, *called, tc.wantCalled)
+				assertEqual(t, *global, tc.wantGlobal)
+				assertEqual(t, *subFlag, tc.wantSubFlag)
+			})
+		}
+	})
+
+	t.Run("sub flag is not available in the root", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			out                   strings.Builder
+			root, _, _, _, called = newRoot(&out)
+		)
+
+		assertErrorContains(t, root.Run(ctx, []string{"--sub-flag", "sub"}), "flag provided but not defined")
+		assertEqual(t, *called, "")
+	})
+
+	t.Run("output is inherited", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			out                strings.Builder
+			root, sub, _, _, _ = newRoot(&out)
+		)
+
+		assertNoError(t, root.Run(ctx, []string{"sub", "-h"}))
+		assertEqual(t, out.String(), sub.Help()+"\n")
+	})
+
+	t.Run("sub flag shadows the global one", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			root, _, global, _, _ = newRoot(new(strings.Builder))
+			local                 string
+			shadow                = &cmd.Command{
+				Name:  "shadow",
+				Flags: []cmd.Flagger{&cmd.Flag[string]{Names: []string{"global", "g"}, Value: &local}},
+			}
+		)
+
+		root.Commands = append(root.Commands, shadow)
+
+		assertNoError(t, root.Run(ctx, []string{"-g", "foo", "shadow", "-g", "bar"}))
+		assertEqual(t, *global, "foo")
+		assertEqual(t, local, "bar")
+		assertEqual(t, strings.Contains(shadow.Help(), "Global options:"), false)
+	})
+}
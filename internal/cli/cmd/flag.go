@@ -257,6 +257,14 @@ func (f *Flag[T]) Apply(s *flag.FlagSet) {
 		f.setValue(v, FlagValueSourceEnv)
 	}
 
+	f.register(s)
+}
+
+// names returns the flag names.
+func (f *Flag[T]) names() []string { return f.Names }
+
+// register registers the flag with the provided flag set, without resetting its current value.
+func (f *Flag[T]) register(s *flag.FlagSet) {
 	switch any(*new(T)).(type) {
 	case bool:
 		var fn = func(string) error {
@@ -0,0 +1,37 @@
+package cli
+
+import "sync"
+
+// dupGroup is the group of the files with the same content, found during the run. Only the first tracked file (the
+// leader) is uploaded, and its compression result is reused for the rest of them (the duplicates).
+type dupGroup struct {
+	Leader string        // path of the leader file
+	Done   chan struct{} // closed once the leader is processed
+	Result fileStat      // the leader processing result (set before the Done channel is closed)
+}
+
+// dupGroups groups the files by the content hash. It's safe for concurrent use.
+type dupGroups struct {
+	mu     sync.Mutex
+	groups map[string]*dupGroup
+}
+
+// newDupGroups creates the empty files groups.
+func newDupGroups() *dupGroups { return &dupGroups{groups: make(map[string]*dupGroup)} }
+
+// Track returns the group for the file with the given content hash, and reports whether the file is the leader
+// of the group (the first tracked file with such content).
+func (g *dupGroups) Track(hash, path string) (_ *dupGroup, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if group, ok := g.groups[hash]; ok {
+		return group, false
+	}
+
+	var group = &dupGroup{Leader: path, Done: make(chan struct{})}
+
+	g.groups[hash] = group
+
+	return group, true
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDupGroups_Track(t *testing.T) {
+	t.Parallel()
+
+	t.Run("leaders and duplicates", func(t *testing.T) {
+		t.Parallel()
+
+		var groups = newDupGroups()
+
+		a, leader := groups.Track("hash-1", "/img/a.png")
+		assertEqual(t, true, leader)
+		assertEqual(t, "/img/a.png", a.Leader)
+
+		b, leader := groups.Track("hash-2", "/img/b.png")
+		assertEqual(t, true, leader)
+		assertEqual(t, "/img/b.png", b.Leader)
+
+		c, leader := groups.Track("hash-1", "/img/c.png")
+		assertEqual(t, false, leader)
+		assertEqual(t, a, c)
+		assertEqual(t, "/img/a.png", c.Leader)
+
+		select {
+		case <-a.Done:
+			t.Error("the group must not be done until the leader is processed")
+		default:
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			groups  = newDupGroups()
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			leaders = make(map[string]int) // hash -> number of leaders
+		)
+
+		for i := range 100 {
+			wg.Go(func() {
+				var hash = "hash-" + strconv.Itoa(i%10)
+
+				if _, leader := groups.Track(hash, "/img/"+strconv.Itoa(i)+".png"); leader {
+					mu.Lock()
+					leaders[hash]++
+					mu.Unlock()
+				}
+			})
+		}
+
+		wg.Wait()
+
+		assertEqual(t, 10, len(leaders))
+
+		for hash, n := range leaders {
+			if n != 1 {
+				t.Errorf("expected exactly one leader for %s, got %d", hash, n)
+			}
+		}
+	})
+}
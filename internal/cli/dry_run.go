@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gh.tarampamp.am/tinifier/v5/internal/cache"
+)
+
+// dryRun walks the same files sequence as the main process (with the same filters applied), and reports which
+// files would be compressed and how many API compressions it would cost - without touching the network or files.
+func (a *App) dryRun(ctx context.Context, paths []string) error {
+	hashCache, cacheErr := a.openCache()
+	if cacheErr != nil {
+		return cacheErr
+	}
+
+	var (
+		iterCtx, cancelIter = context.WithCancel(ctx)
+		skippedPaths        atomic.Uint64 // the paths skipped by the finder because of the errors
+	)
+
+	defer cancelIter()
+
+	filesSeq, findErr := a.findFiles(iterCtx, paths, a.diagnosePaths(&skippedPaths, cancelIter))
+	if findErr != nil {
+		return findErr
+	}
+
+	if err := a.strictPathsError(skippedPaths.Load()); err != nil { // the given paths are already checked
+		return err
+	}
+
+	var (
+		stats      fileStats
+		toCompress uint
+		estimated  uint // the number of API compressions
+		startedAt  = time.Now()
+		dups       *dupGroups        // nil if the files de-duplication is disabled
+		claimed    map[string]string // output path -> input path (only when the output directory is set)
+		roots      = absPaths(paths)
+	)
+
+	if a.opt.DedupFiles() {
+		dups = newDupGroups()
+	}
+
+	if a.opt.OutputDir != "" {
+		claimed = make(map[string]string)
+	}
+
+	for path := range filesSeq {
+		stat, statErr := os.Stat(path)
+		if statErr != nil {
+			a.errorf("failed to get the file info (%s): %s", filepath.Base(path), statErr)
+
+			continue
+		}
+
+		if claimed != nil {
+			if err := claimOutputs(claimed, a.outputPaths(roots, path), path); err != nil {
+				a.errorf("%s", err)
+
+				continue
+			}
+		}
+
+		var mimeType, _ = convertMimeType(strings.TrimPrefix(filepath.Ext(path), "."))
+
+		if a.opt.DetectByContent {
+			var detectErr error
+
+			if mimeType, detectErr = detectImageType(path); detectErr != nil {
+				a.errorf("file rejected (%s): %s", filepath.Base(path), detectErr)
+
+				continue
+			}
+		}
+
+		var fStat = fileStat{Path: path, Type: mimeType, OrigSize: uint64(stat.Size()), DryRun: true} //nolint:gosec
+
+		if hashCache != nil || dups != nil {
+			if hash, hashErr := cache.HashFile(path); hashErr == nil {
+				if hashCache != nil { // files found in the cache would be skipped
+					if _, cached := hashCache.Get(a.cacheKey(hash)); cached {
+						fStat.DryRun, fStat.Skipped, fStat.Cached = false, true, true
+						fStat.SkipReason = skipReasonCached
+					}
+				}
+
+				if dups != nil { // duplicates would reuse the compression result of the first found file
+					if group, leader := dups.Track(hash, path); !leader {
+						fStat.DuplicateOf = group.Leader
+					}
+				}
+			}
+		}
+
+		if fStat.DryRun && fStat.DuplicateOf == "" {
+			toCompress++
+			estimated += a.opt.CompressionsPerFile(mimeType)
+		}
+
+		stats.Add(fStat)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := a.strictPathsError(skippedPaths.Load()); err != nil {
+		return err
+	}
+
+	if len(stats.Items) == 0 {
+		a.logf("Dry run: no files to compress found")
+
+		return a.report(&stats, time.Since(startedAt))
+	}
+
+	if table := stats.Table(); table != "" {
+		a.logf("%s\n", table)
+	}
+
+	a.logf("Dry run: %d file(s) would be processed, estimated API compressions: %d",
+		toCompress,
+		estimated,
+	)
+
+	if dupes := stats.Duplicates(); dupes > 0 {
+		a.logf("Dry run: %d duplicate file(s) would reuse the compression results", dupes)
+	}
+
+	return a.report(&stats, time.Since(startedAt))
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"gh.tarampamp.am/tinifier/v5/internal/cli/cmd"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
+)
+
+// newFakeServerCommand creates the (hidden) command that starts the fake TinyPNG API server, which can be used
+// for the end-to-end testing without the network access and spending the real quota (use the `--api-url` flag
+// of the main command to point it to the fake server).
+func newFakeServerCommand() *cmd.Command { //nolint:funlen
+	var (
+		c = cmd.Command{
+			Name:        "fake-server",
+			Description: "Start the fake TinyPNG API server for offline testing.",
+			Usage:       "[<options>]",
+			Hidden:      true, // not listed in the help output
+		}
+
+		listen = cmd.Flag[string]{
+			Names:   []string{"listen", "l"},
+			Usage:   "Address to listen on",
+			EnvVars: []string{"FAKE_SERVER_LISTEN"},
+			Default: "127.0.0.1:8080",
+		}
+		acceptKeys = cmd.Flag[string]{ // not "api-key", since it would shadow the global flag with the same name
+			Names:   []string{"accept-key"},
+			Usage:   "API keys accepted by the server (separated by commas; any key is accepted if not set)",
+			EnvVars: []string{"FAKE_SERVER_ACCEPT_KEYS"},
+		}
+		quotaLimit = cmd.Flag[uint64]{
+			Names:   []string{"quota-limit"},
+			Usage:   "Maximum number of compressions per API key (set 0 to disable)",
+			EnvVars: []string{"FAKE_SERVER_QUOTA_LIMIT"},
+		}
+		ratio = cmd.Flag[float64]{
+			Names:   []string{"ratio"},
+			Usage:   "Ratio of the compressed image size to the original one [0.00 - 1.00]",
+			EnvVars: []string{"FAKE_SERVER_RATIO"},
+			Default: 0.7, //nolint:mnd
+		}
+	)
+
+	c.Flags = []cmd.Flagger{&listen, &acceptKeys, &quotaLimit, &ratio}
+
+	c.Action = func(ctx context.Context, _ *cmd.Command, _ []string) error {
+		var opts = []tinypngtest.Option{
+			tinypngtest.WithQuotaLimit(*quotaLimit.Value),
+			tinypngtest.WithCompressionRatio(*ratio.Value),
+		}
+
+		if keys := cleanStrings(*acceptKeys.Value, ","); len(keys) > 0 {
+			opts = append(opts, tinypngtest.WithAPIKeys(keys...))
+		}
+
+		var srv = &http.Server{
+			Handler:           tinypngtest.NewHandler(opts...),
+			ReadHeaderTimeout: 10 * time.Second, //nolint:mnd
+		}
+
+		ln, lnErr := net.Listen("tcp", *listen.Value)
+		if lnErr != nil {
+			return lnErr
+		}
+
+		_, _ = fmt.Fprintf(os.Stdout, "Fake TinyPNG API server is listening on http://%s\n", ln.Addr())
+
+		var serveErr = make(chan error, 1)
+
+		go func() { defer close(serveErr); serveErr <- srv.Serve(ln) }()
+
+		select {
+		case err := <-serveErr:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:mnd,contextcheck
+			defer cancel()
+
+			if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) { //nolint:contextcheck
+				return err
+			}
+
+			return nil
+		}
+	}
+
+	return &c
+}
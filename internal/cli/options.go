@@ -2,36 +2,114 @@ package cli
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"gh.tarampamp.am/tinifier/v5/internal/cache"
 	"gh.tarampamp.am/tinifier/v5/internal/config"
+	"gh.tarampamp.am/tinifier/v5/internal/finder"
+	"gh.tarampamp.am/tinifier/v5/internal/humanize"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
 )
 
 type options struct {
 	ApiKeys             []string
+	ApiURL              string
+	KeyQuotaLimit       uint64 // 0 means no limit
+	KeysStrategy        string
+	MaxInFlightPerKey   uint // 0 means no limit
+	KeyCoolDown         time.Duration
 	FileExtensions      []string
+	DetectByContent     bool // select the files by the content instead of the extensions
 	ThreadsCount        uint
 	MaxErrorsToStop     uint
 	RetryAttempts       uint
-	DelayBetweenRetries time.Duration
+	DelayBetweenRetries time.Duration // the base delay for the exponential backoff
+	RetryBackoff        string
+	RetryMaxDelay       time.Duration // 0 means no limit
+	RetryJitter         bool
 	Recursive           bool
-	SkipIfDiffLessThan  float64 // in percents [0.00 - 100.00]
+	FollowSymlinks      bool
+	Hidden              bool      // include the hidden files and directories
+	MaxDepth            uint      // 0 means no limit
+	StrictPaths         bool      // fail if any path can't be accessed
+	Include             []string  // glob patterns of the files to process, relative to the given directories
+	Exclude             []string  // glob patterns of the files (and directories) to skip
+	UseGitignore        bool      // honor the .gitignore files in addition to the .tinifierignore ones
+	MinSize             uint64    // in bytes, 0 means no limit
+	MaxSize             uint64    // in bytes, 0 means no limit
+	NewerThan           time.Time // zero means no limit
+	OlderThan           time.Time // zero means no limit
+	SkipIfDiffLessThan  float64   // in percents [0.00 - 100.00]
 	PreserveTime        bool
 	KeepOriginalFile    bool
+	ResizeMethod        string // empty means no resizing
+	ResizeWidth         uint
+	ResizeHeight        uint
+	ConvertTo           []string // formats (file extensions) to convert to; empty means no conversion
+	ConvertBackground   string
+	Store               storeOptions
+	DryRun              bool
+	CacheFile           string // empty means the cache is disabled
+	NoCache             bool
+	NoDedup             bool   // upload the files with the same content separately
+	ReportFormat        string // empty means no report
+	ReportFile          string // "-" means stdout
+	JUnitReportFile     string // empty means no JUnit report
+	OutputDir           string // empty means the original files are replaced
+	OutputExists        string // what to do with the files that already exist in the output directory
+}
+
+// Policies for the files that already exist in the output directory.
+const (
+	outputExistsOverwrite = "overwrite"
+	outputExistsSkip      = "skip"
+)
+
+// Supported backoff strategies of the retries.
+const (
+	retryBackoffFixed       = "fixed"
+	retryBackoffExponential = "exponential"
+)
+
+// Supported cloud storage services.
+const (
+	storeServiceS3  = "s3"
+	storeServiceGCS = "gcs"
+)
+
+type storeOptions struct {
+	Service           string // empty means the compressed files are not stored in the cloud
+	PathTemplate      string // bucket name and the object path, e.g. "bucket/images/{name}"
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3Region          string
+	GCSAccessToken    string
 }
 
 func newOptionsWithDefaults() options {
 	return options{
+		ApiURL:              tinypng.DefaultBaseURL,
+		KeysStrategy:        string(tinypng.StrategyMostRemaining),
+		KeyCoolDown:         tinypng.DefaultCoolDown,
 		FileExtensions:      []string{"png", "jpeg", "jpg", "webp", "avif"},
 		ThreadsCount:        16, //nolint:mnd
 		MaxErrorsToStop:     10, //nolint:mnd
 		RetryAttempts:       3,  //nolint:mnd
 		DelayBetweenRetries: time.Second,
+		RetryBackoff:        retryBackoffFixed,
+		RetryMaxDelay:       30 * time.Second, //nolint:mnd
 		Recursive:           false,
 		SkipIfDiffLessThan:  1, // 1.00% by default
 		PreserveTime:        false,
 		KeepOriginalFile:    false,
+		CacheFile:           filepath.Join(config.DefaultDirPath(), cache.FileName),
+		ReportFile:          reportToStdout,
+		OutputExists:        outputExistsOverwrite,
 	}
 }
 
@@ -52,7 +130,111 @@ func (o *options) UpdateFromConfigFile(filePath string) error {
 	}
 
 	setIfSourceNotNil(&o.ApiKeys, cfg.ApiKeys)
-	// add other fields here
+	setIfSourceNotNil(&o.ApiURL, cfg.ApiURL)
+	setIfSourceNotNil(&o.KeyQuotaLimit, cfg.KeyQuotaLimit)
+	setIfSourceNotNil(&o.KeysStrategy, cfg.KeysStrategy)
+	setIfSourceNotNil(&o.MaxInFlightPerKey, cfg.MaxInFlightPerKey)
+	setIfSourceNotNil(&o.DetectByContent, cfg.DetectByContent)
+	setIfSourceNotNil(&o.Include, cfg.Include)
+	setIfSourceNotNil(&o.Exclude, cfg.Exclude)
+	setIfSourceNotNil(&o.UseGitignore, cfg.UseGitignore)
+	setIfSourceNotNil(&o.Hidden, cfg.Hidden)
+	setIfSourceNotNil(&o.FollowSymlinks, cfg.FollowSymlinks)
+	setIfSourceNotNil(&o.RetryAttempts, cfg.RetryAttempts)
+	setIfSourceNotNil(&o.RetryBackoff, cfg.RetryBackoff)
+	setIfSourceNotNil(&o.RetryJitter, cfg.RetryJitter)
+	setIfSourceNotNil(&o.CacheFile, cfg.CacheFile)
+	setIfSourceNotNil(&o.ReportFormat, cfg.Report)
+	setIfSourceNotNil(&o.ReportFile, cfg.ReportFile)
+	setIfSourceNotNil(&o.JUnitReportFile, cfg.JUnitReport)
+
+	for _, err := range []error{
+		parseIfSourceNotNil(&o.KeyCoolDown, cfg.KeyCoolDown, time.ParseDuration),
+		parseIfSourceNotNil(&o.MinSize, cfg.MinSize, humanize.ParseBytes),
+		parseIfSourceNotNil(&o.MaxSize, cfg.MaxSize, humanize.ParseBytes),
+		parseIfSourceNotNil(&o.NewerThan, cfg.NewerThan, parseTimeBound),
+		parseIfSourceNotNil(&o.OlderThan, cfg.OlderThan, parseTimeBound),
+		parseIfSourceNotNil(&o.DelayBetweenRetries, cfg.DelayBetweenRetries, time.ParseDuration),
+		parseIfSourceNotNil(&o.RetryMaxDelay, cfg.RetryMaxDelay, time.ParseDuration),
+	} {
+		if err != nil {
+			return fmt.Errorf("wrong value in the configuration file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// parseTimeBound parses the time bound for the files filtering. It can be a duration ago (e.g. "72h", "30m" or
+// "7d" for days) or an absolute date/time (e.g. "2025-01-31", "2025-01-31 15:04" or RFC 3339), in the local
+// time zone if the zone is not specified.
+func parseTimeBound(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if days, found := strings.CutSuffix(s, "d"); found {
+		if n, err := strconv.ParseUint(days, 10, 16); err == nil {
+			return time.Now().AddDate(0, 0, -int(n)), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{time.DateOnly, time.DateTime, "2006-01-02 15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid duration or date %q", s)
+}
+
+// DedupFiles reports whether the files with the same content are compressed only once per run. The compressed
+// file is copied over the duplicates, so it's applicable only when the original files are replaced.
+func (o *options) DedupFiles() bool {
+	return !o.NoDedup && o.OutputDir == "" && len(o.ConvertTo) == 0 && o.Store.Service == ""
+}
+
+// CompressionsPerFile returns the estimated number of API compressions spent on a single file of the given MIME
+// type. Each file upload costs one compression; resizing and conversion cost one additional compression per
+// downloaded (converted) output. The conversions to the file's own format are skipped, so they cost nothing.
+func (o *options) CompressionsPerFile(mimeType string) uint {
+	var outputs, perOutput uint = 1, 0
+
+	if len(o.ConvertTo) > 0 {
+		outputs, perOutput = 0, 1
+
+		for _, format := range o.ConvertTo {
+			if convMime, _ := convertMimeType(format); !strings.EqualFold(convMime, mimeType) {
+				outputs++
+			}
+		}
+	}
+
+	if o.ResizeMethod != "" {
+		perOutput++
+	}
+
+	return 1 + outputs*perOutput
+}
+
+// parseIfSourceNotNil parses the source value and sets the result to the target, if both are not nil.
+func parseIfSourceNotNil[T any](target *T, source *string, parse func(string) (T, error)) error {
+	if target == nil || source == nil {
+		return nil
+	}
+
+	v, err := parse(*source)
+	if err != nil {
+		return err
+	}
+
+	*target = v
 
 	return nil
 }
@@ -67,14 +249,38 @@ func setIfSourceNotNil[T any](target, source *T) {
 }
 
 func (o *options) Validate() error {
-	if len(o.ApiKeys) == 0 {
+	if len(o.ApiKeys) == 0 && !o.DryRun { // keys are not needed for the dry run
 		return fmt.Errorf("API keys list cannot be empty")
 	}
 
+	if u, err := url.Parse(o.ApiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("API URL must be a valid HTTP(S) URL")
+	}
+
+	if _, err := tinypng.ParsePoolStrategy(o.KeysStrategy); err != nil {
+		return err
+	}
+
 	if len(o.FileExtensions) == 0 {
 		return fmt.Errorf("extensions list cannot be empty")
 	}
 
+	if _, err := finder.FilterByGlob(o.Include...); err != nil {
+		return fmt.Errorf("wrong include pattern: %w", err)
+	}
+
+	if _, err := finder.FilterOutByGlob(o.Exclude...); err != nil {
+		return fmt.Errorf("wrong exclude pattern: %w", err)
+	}
+
+	if o.MaxSize > 0 && o.MinSize > o.MaxSize {
+		return fmt.Errorf("min size cannot be greater than max size")
+	}
+
+	if !o.NewerThan.IsZero() && !o.OlderThan.IsZero() && !o.NewerThan.Before(o.OlderThan) {
+		return fmt.Errorf("the newer-than time must be before the older-than one")
+	}
+
 	if o.RetryAttempts == 0 {
 		return fmt.Errorf("retry attempts cannot be zero")
 	}
@@ -83,9 +289,86 @@ func (o *options) Validate() error {
 		return fmt.Errorf("delay between retries cannot be negative")
 	}
 
+	switch o.RetryBackoff {
+	case retryBackoffFixed, retryBackoffExponential:
+	default:
+		return fmt.Errorf("unsupported retry backoff %q", o.RetryBackoff)
+	}
+
+	if o.RetryMaxDelay < 0 {
+		return fmt.Errorf("max delay between retries cannot be negative")
+	}
+
 	if o.ThreadsCount == 0 {
 		return fmt.Errorf("threads count cannot be zero")
 	}
 
+	switch method := tinypng.ResizeMethod(o.ResizeMethod); method {
+	case "":
+		if o.ResizeWidth > 0 || o.ResizeHeight > 0 {
+			return fmt.Errorf("resize method must be set when the resize width or height is specified")
+		}
+	case tinypng.ResizeScale:
+		if (o.ResizeWidth == 0) == (o.ResizeHeight == 0) {
+			return fmt.Errorf("resize method %q requires exactly one of the width or height", method)
+		}
+	case tinypng.ResizeFit, tinypng.ResizeCover, tinypng.ResizeThumb:
+		if o.ResizeWidth == 0 || o.ResizeHeight == 0 {
+			return fmt.Errorf("resize method %q requires both the width and height", method)
+		}
+	default:
+		return fmt.Errorf("unsupported resize method %q", method)
+	}
+
+	for _, format := range o.ConvertTo {
+		if _, ok := convertMimeType(format); !ok {
+			return fmt.Errorf("unsupported conversion format %q", format)
+		}
+	}
+
+	switch o.Store.Service {
+	case "":
+	case storeServiceS3, storeServiceGCS:
+		if o.Store.PathTemplate == "" {
+			return fmt.Errorf("store path cannot be empty")
+		}
+
+		if len(o.ConvertTo) > 0 {
+			return fmt.Errorf("storing in the cloud cannot be combined with the conversion")
+		}
+
+		if o.Store.Service == storeServiceS3 &&
+			(o.Store.S3AccessKeyID == "" || o.Store.S3SecretAccessKey == "" || o.Store.S3Region == "") {
+			return fmt.Errorf("S3 access key ID, secret access key and region are required")
+		}
+
+		if o.Store.Service == storeServiceGCS && o.Store.GCSAccessToken == "" {
+			return fmt.Errorf("GCS access token is required")
+		}
+	default:
+		return fmt.Errorf("unsupported store service %q", o.Store.Service)
+	}
+
+	switch o.OutputExists {
+	case outputExistsOverwrite, outputExistsSkip:
+	default:
+		return fmt.Errorf("unsupported output exists policy %q", o.OutputExists)
+	}
+
+	if o.OutputDir != "" && o.Store.Service != "" {
+		return fmt.Errorf("output directory cannot be combined with storing in the cloud")
+	}
+
+	switch o.ReportFormat {
+	case "", reportFormatJSON, reportFormatNDJSON, reportFormatCSV:
+	default:
+		return fmt.Errorf("unsupported report format %q", o.ReportFormat)
+	}
+
+	if toStdout := o.ReportFile == "" || o.ReportFile == reportToStdout; toStdout && o.ReportFormat != "" &&
+		o.JUnitReportFile == reportToStdout {
+		return fmt.Errorf("the report and the JUnit report cannot be both written to stdout")
+	}
+
 	return nil
 }
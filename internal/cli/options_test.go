@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions_CompressionsPerFile(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveResize  string
+		giveConvert []string
+		giveType    string
+		want        uint
+	}{
+		"compression only":          {want: 1},
+		"resize":                    {giveResize: "fit", want: 2},
+		"convert to one format":     {giveConvert: []string{"webp"}, giveType: "image/png", want: 2},
+		"convert to several":        {giveConvert: []string{"webp", "avif", "jpg"}, giveType: "image/png", want: 4},
+		"resize and convert":        {giveResize: "scale", giveConvert: []string{"webp"}, want: 3},
+		"resize and convert to two": {giveResize: "cover", giveConvert: []string{"webp", "avif"}, want: 5},
+		"convert to the same format": {
+			giveConvert: []string{"png"}, giveType: "image/png", want: 1, // only the upload
+		},
+		"convert to the same and another format": {
+			giveConvert: []string{"jpg", "webp"}, giveType: "image/jpeg", want: 2,
+		},
+		"resize and convert to the same and another format": {
+			giveResize: "fit", giveConvert: []string{"webp", "png"}, giveType: "image/png", want: 3,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var o = newOptionsWithDefaults()
+
+			o.ResizeMethod, o.ConvertTo = tc.giveResize, tc.giveConvert
+
+			assertEqual(t, tc.want, o.CompressionsPerFile(tc.giveType))
+		})
+	}
+}
+
+func TestOptions_Validate_Reports(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveFormat, giveFile, giveJUnit string
+		wantErr                         bool
+	}{
+		"no reports":                  {},
+		"report to stdout":            {giveFormat: reportFormatJSON, giveFile: reportToStdout},
+		"junit to stdout":             {giveJUnit: reportToStdout},
+		"report to file, junit to -":  {giveFormat: reportFormatCSV, giveFile: "report.csv", giveJUnit: reportToStdout},
+		"report to -, junit to file":  {giveFormat: reportFormatCSV, giveFile: reportToStdout, giveJUnit: "junit.xml"},
+		"both to stdout":              {giveFormat: reportFormatJSON, giveFile: "-", giveJUnit: "-", wantErr: true},
+		"both to stdout (empty file)": {giveFormat: reportFormatNDJSON, giveJUnit: reportToStdout, wantErr: true},
+		"unsupported format":          {giveFormat: "xml", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var o = newOptionsWithDefaults()
+
+			o.ApiKeys = []string{"key"}
+			o.ReportFormat, o.ReportFile, o.JUnitReportFile = tc.giveFormat, tc.giveFile, tc.giveJUnit
+
+			if err := o.Validate(); tc.wantErr {
+				assertError(t, err)
+			} else {
+				assertNoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptions_UpdateFromConfigFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all options", func(t *testing.T) {
+		t.Parallel()
+
+		var path = filepath.Join(t.TempDir(), "config.yml")
+
+		writeFile(t, path, `
+apiKeys: [foo, bar]
+keyQuotaLimit: 500
+keysStrategy: round-robin
+maxInFlightPerKey: 2
+keyCoolDown: 1m
+detectByContent: true
+include: ["**/*.png"]
+exclude: [vendor/**]
+useGitignore: true
+hidden: true
+followSymlinks: true
+minSize: 1KB
+maxSize: 5MB
+retryAttempts: 5
+delayBetweenRetries: 2s
+retryBackoff: exponential
+retryMaxDelay: 10s
+retryJitter: true
+cacheFile: /tmp/tinifier.cache
+report: csv
+reportFile: report.csv
+junitReport: junit.xml
+`)
+
+		var o = newOptionsWithDefaults()
+
+		assertNoError(t, o.UpdateFromConfigFile(path))
+
+		assertEqual(t, "foo,bar", strings.Join(o.ApiKeys, ","))
+		assertEqual(t, uint64(500), o.KeyQuotaLimit)
+		assertEqual(t, "round-robin", o.KeysStrategy)
+		assertEqual(t, uint(2), o.MaxInFlightPerKey)
+		assertEqual(t, time.Minute, o.KeyCoolDown)
+		assertEqual(t, true, o.DetectByContent)
+		assertEqual(t, "**/*.png", strings.Join(o.Include, ","))
+		assertEqual(t, "vendor/**", strings.Join(o.Exclude, ","))
+		assertEqual(t, true, o.UseGitignore)
+		assertEqual(t, true, o.Hidden)
+		assertEqual(t, true, o.FollowSymlinks)
+		assertEqual(t, uint64(1024), o.MinSize)
+		assertEqual(t, uint64(5<<20), o.MaxSize)
+		assertEqual(t, uint(5), o.RetryAttempts)
+		assertEqual(t, 2*time.Second, o.DelayBetweenRetries)
+		assertEqual(t, retryBackoffExponential, o.RetryBackoff)
+		assertEqual(t, 10*time.Second, o.RetryMaxDelay)
+		assertEqual(t, true, o.RetryJitter)
+		assertEqual(t, "/tmp/tinifier.cache", o.CacheFile)
+		assertEqual(t, reportFormatCSV, o.ReportFormat)
+		assertEqual(t, "report.csv", o.ReportFile)
+		assertEqual(t, "junit.xml", o.JUnitReportFile)
+		assertNoError(t, o.Validate())
+	})
+
+	t.Run("unset options keep the defaults", func(t *testing.T) {
+		t.Parallel()
+
+		var path = filepath.Join(t.TempDir(), "config.yml")
+
+		writeFile(t, path, "apiKeys: [foo]\n")
+
+		var o, want = newOptionsWithDefaults(), newOptionsWithDefaults()
+
+		assertNoError(t, o.UpdateFromConfigFile(path))
+
+		assertEqual(t, want.KeyQuotaLimit, o.KeyQuotaLimit)
+		assertEqual(t, want.KeysStrategy, o.KeysStrategy)
+		assertEqual(t, want.KeyCoolDown, o.KeyCoolDown)
+		assertEqual(t, want.MinSize, o.MinSize)
+		assertEqual(t, want.NewerThan, o.NewerThan)
+		assertEqual(t, want.RetryAttempts, o.RetryAttempts)
+		assertEqual(t, want.DelayBetweenRetries, o.DelayBetweenRetries)
+		assertEqual(t, want.CacheFile, o.CacheFile)
+		assertEqual(t, want.ReportFile, o.ReportFile)
+	})
+
+	t.Run("wrong value", func(t *testing.T) {
+		t.Parallel()
+
+		for name, content := range map[string]string{
+			"duration": "keyCoolDown: soon\n",
+			"size":     "maxSize: huge\n",
+			"time":     "newerThan: yesterday\n",
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				var path = filepath.Join(t.TempDir(), "config.yml")
+
+				writeFile(t, path, content)
+
+				var o = newOptionsWithDefaults()
+
+				assertError(t, o.UpdateFromConfigFile(path))
+			})
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+
+		var o = newOptionsWithDefaults()
+
+		assertNoError(t, o.UpdateFromConfigFile(filepath.Join(t.TempDir(), "missing.yml")))
+	})
+}
+
+func TestParseTimeBound(t *testing.T) {
+	t.Parallel()
+
+	const tolerance = time.Minute // the relative bounds depend on the current time
+
+	for name, tc := range map[string]struct {
+		give    string
+		want    func() time.Time
+		wantErr bool
+	}{
+		"days":          {give: "7d", want: func() time.Time { return time.Now().AddDate(0, 0, -7) }},
+		"zero days":     {give: "0d", want: time.Now},
+		"hours":         {give: "72h", want: func() time.Time { return time.Now().Add(-72 * time.Hour) }},
+		"minutes":       {give: " 30m ", want: func() time.Time { return time.Now().Add(-30 * time.Minute) }},
+		"combined":      {give: "1h30m", want: func() time.Time { return time.Now().Add(-90 * time.Minute) }},
+		"negative":      {give: "-1h", wantErr: true},
+		"negative days": {give: "-7d", wantErr: true},
+		"unknown unit":  {give: "7w", wantErr: true},
+		"empty":         {give: "", wantErr: true},
+		"garbage":       {give: "yesterday", wantErr: true},
+		"invalid date":  {give: "2025-02-31", wantErr: true},
+		"rfc3339 utc":   {give: "2025-01-31T15:04:05Z", want: fixedTime(time.Date(2025, 1, 31, 15, 4, 5, 0, time.UTC))},
+		"rfc3339 offset": {
+			give: "2025-01-31T15:04:05+02:00",
+			want: fixedTime(time.Date(2025, 1, 31, 13, 4, 5, 0, time.UTC)),
+		},
+		"local date":       {give: "2025-01-31", want: fixedTime(time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local))},
+		"local date time":  {give: "2025-01-31 15:04:05", want: fixedTime(time.Date(2025, 1, 31, 15, 4, 5, 0, time.Local))},
+		"local no seconds": {give: "2025-01-31 15:04", want: fixedTime(time.Date(2025, 1, 31, 15, 4, 0, 0, time.Local))},
+		"local iso":        {give: "2025-01-31T15:04:05", want: fixedTime(time.Date(2025, 1, 31, 15, 4, 5, 0, time.Local))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseTimeBound(tc.give)
+
+			if tc.wantErr {
+				assertError(t, err)
+
+				return
+			}
+
+			assertNoError(t, err)
+
+			if diff := got.Sub(tc.want()).Abs(); diff > tolerance {
+				t.Errorf("expected %s, got %s", tc.want(), got)
+			}
+		})
+	}
+}
+
+// fixedTime returns the function that always returns the given time.
+func fixedTime(t time.Time) func() time.Time { return func() time.Time { return t } }
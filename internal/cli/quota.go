@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gh.tarampamp.am/tinifier/v5/internal/cli/cmd"
+	"gh.tarampamp.am/tinifier/v5/internal/errgroup"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+)
+
+// freeTierQuota is the number of free compressions per month for the TinyPNG API key.
+const freeTierQuota = 500
+
+// keyQuota is the quota usage of a single API key.
+type keyQuota struct {
+	Key       string  `json:"key"` // masked
+	Used      uint64  `json:"used"`
+	Remaining *uint64 `json:"remaining"` // nil if the limit is not set (or the usage is unknown)
+	Invalid   bool    `json:"invalid"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// newQuotaCommand creates the command that prints the used and remaining compressions for every configured API key.
+// The configuration file, API keys and URL flags are inherited from the parent (global) command.
+func newQuotaCommand(configFile, apiKeys, apiURL *cmd.Flag[string]) *cmd.Command { //nolint:funlen
+	var (
+		c = cmd.Command{
+			Name:        "quota",
+			Description: "Show the used and remaining compressions for the API keys.",
+			Usage:       "[<options>]",
+		}
+
+		limit = cmd.Flag[uint64]{
+			Names:   []string{"limit"},
+			Usage:   "Monthly compressions limit per API key (500 for the free tier, 0 means no limit)",
+			EnvVars: []string{"QUOTA_LIMIT"},
+			Default: freeTierQuota,
+		}
+		asJSON = cmd.Flag[bool]{
+			Names:   []string{"json"},
+			Usage:   "Print the result in JSON format",
+			EnvVars: []string{"QUOTA_JSON"},
+		}
+	)
+
+	c.Flags = []cmd.Flagger{&limit, &asJSON}
+
+	c.Action = func(ctx context.Context, _ *cmd.Command, _ []string) error {
+		var opt = newOptionsWithDefaults()
+
+		if err := opt.UpdateFromConfigFile(*configFile.Value); err != nil {
+			return err
+		}
+
+		if apiKeys.IsSet() && apiKeys.Value != nil {
+			if clean := cleanStrings(*apiKeys.Value, ","); len(clean) > 0 {
+				opt.ApiKeys = clean
+			}
+		}
+
+		setIfFlagIsSet(&opt.ApiURL, *apiURL)
+
+		if len(opt.ApiKeys) == 0 {
+			return errors.New("API keys list cannot be empty")
+		}
+
+		var quotas = fetchQuotas(ctx, opt.ApiKeys, *limit.Value, tinypng.WithBaseURL(opt.ApiURL))
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return writeQuotas(os.Stdout, quotas, *asJSON.Value)
+	}
+
+	return &c
+}
+
+// writeQuotas writes the quota usage as a JSON array, or as a human-readable table.
+func writeQuotas(w io.Writer, quotas []keyQuota, asJSON bool) error {
+	if asJSON {
+		var enc = json.NewEncoder(w)
+
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(quotas)
+	}
+
+	return writeQuotaTable(w, quotas)
+}
+
+// fetchQuotas queries the quota usage for all the API keys concurrently. The result order matches the keys order.
+// The zero limit means no limit, so the remaining compressions are not calculated.
+func fetchQuotas(ctx context.Context, keys []string, limit uint64, opts ...tinypng.ClientOption) []keyQuota {
+	var (
+		quotas = make([]keyQuota, len(keys))
+		eg, _  = errgroup.New(ctx)
+	)
+
+	for i, key := range keys {
+		eg.Go(func(ctx context.Context) error {
+			var q = keyQuota{Key: maskApiKey(key)}
+
+			used, err := tinypng.NewClient(key, opts...).UsedQuota(ctx)
+
+			switch {
+			case errors.Is(err, tinypng.ErrUnauthorized):
+				q.Invalid = true
+			case err != nil:
+				q.Error = err.Error()
+			default:
+				q.Used = used
+
+				if limit > 0 {
+					var remaining uint64
+
+					if used < limit {
+						remaining = limit - used
+					}
+
+					q.Remaining = &remaining
+				}
+			}
+
+			quotas[i] = q
+
+			return nil // errors are reported per key, so they should not cancel other requests
+		})
+	}
+
+	_ = eg.Wait()
+
+	return quotas
+}
+
+// writeQuotaTable writes the quota usage as a human-readable table.
+func writeQuotaTable(w io.Writer, quotas []keyQuota) error {
+	var rows = [][4]string{{"Key", "Used", "Remaining", "Status"}}
+
+	for _, q := range quotas {
+		var status = "ok"
+
+		switch {
+		case q.Invalid:
+			status = "invalid"
+		case q.Error != "":
+			status = "error: " + q.Error
+		case q.Remaining != nil && *q.Remaining == 0:
+			status = "exhausted"
+		}
+
+		switch {
+		case q.Invalid || q.Error != "":
+			rows = append(rows, [4]string{q.Key, "-", "-", status})
+		case q.Remaining == nil: // no limit
+			rows = append(rows, [4]string{q.Key, strconv.FormatUint(q.Used, 10), "-", status})
+		default:
+			rows = append(rows, [4]string{
+				q.Key, strconv.FormatUint(q.Used, 10), strconv.FormatUint(*q.Remaining, 10), status,
+			})
+		}
+	}
+
+	var widths [3]int // the last column is not padded
+
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+
+	var b strings.Builder
+
+	for _, row := range rows {
+		for i := range widths {
+			b.WriteString(row[i])
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(row[i])+2)) //nolint:mnd
+		}
+
+		b.WriteString(row[3])
+		b.WriteRune('\n')
+	}
+
+	_, err := fmt.Fprint(w, b.String())
+
+	return err
+}
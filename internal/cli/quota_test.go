@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
+)
+
+func TestFetchQuotas(t *testing.T) {
+	t.Parallel()
+
+	const (
+		usedKey   = "used-key-0001"
+		unusedKey = "unused-key-02"
+		badKey    = "invalid-key-3"
+	)
+
+	var srv = tinypngtest.NewServer(tinypngtest.WithAPIKeys(usedKey, unusedKey))
+
+	t.Cleanup(srv.Close)
+
+	for range 2 { // spend some compressions
+		_, err := tinypng.NewClient(usedKey, tinypng.WithBaseURL(srv.URL)).
+			Compress(t.Context(), bytes.NewReader([]byte("\x89PNG\r\n\x1a\n fake image content")))
+		assertNoError(t, err)
+	}
+
+	t.Run("keys", func(t *testing.T) {
+		t.Parallel()
+
+		var got = fetchQuotas(t.Context(), []string{usedKey, unusedKey, badKey}, 3, tinypng.WithBaseURL(srv.URL))
+
+		assertEqual(t, 3, len(got)) // the order matches the keys order
+		assertEqual(t, "used…0001 used=2 remaining=1 invalid=false", quotaString(got[0]))
+		assertEqual(t, "unus…y-02 used=0 remaining=3 invalid=false", quotaString(got[1]))
+		assertEqual(t, "inva…ey-3 used=0 remaining=- invalid=true", quotaString(got[2]))
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		var got = fetchQuotas(t.Context(), []string{usedKey}, 1, tinypng.WithBaseURL(srv.URL))
+
+		assertEqual(t, "used…0001 used=2 remaining=0 invalid=false", quotaString(got[0]))
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		t.Parallel()
+
+		var got = fetchQuotas(t.Context(), []string{usedKey}, 0, tinypng.WithBaseURL(srv.URL))
+
+		assertEqual(t, "used…0001 used=2 remaining=- invalid=false", quotaString(got[0]))
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		t.Parallel()
+
+		var closed = tinypngtest.NewServer()
+
+		closed.Close()
+
+		var got = fetchQuotas(t.Context(), []string{usedKey}, 500, tinypng.WithBaseURL(closed.URL))
+
+		assertEqual(t, 1, len(got))
+		assertEqual(t, false, got[0].Invalid)
+
+		if got[0].Error == "" {
+			t.Error("expected the error to be reported")
+		}
+	})
+}
+
+func TestWriteQuotas(t *testing.T) {
+	t.Parallel()
+
+	var quotas = []keyQuota{
+		{Key: "used…0001", Used: 2, Remaining: toPtr[uint64](498)},
+		{Key: "full…0002", Used: 500, Remaining: toPtr[uint64](0)},
+		{Key: "unli…0005", Used: 1200},
+		{Key: "inva…ey-3", Invalid: true},
+		{Key: "down…ey-4", Error: "connection refused"},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		assertNoError(t, writeQuotas(&buf, quotas, false))
+
+		assertEqual(t, strings.Join([]string{
+			"Key        Used  Remaining  Status",
+			"used…0001  2     498        ok",
+			"full…0002  500   0          exhausted",
+			"unli…0005  1200  -          ok",
+			"inva…ey-3  -     -          invalid",
+			"down…ey-4  -     -          error: connection refused",
+			"",
+		}, "\n"), buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+
+		assertNoError(t, writeQuotas(&buf, quotas, true))
+
+		var got []map[string]any
+
+		assertNoError(t, json.Unmarshal(buf.Bytes(), &got))
+		assertEqual(t, 5, len(got))
+		assertEqual(t, "used…0001", got[0]["key"].(string))
+		assertEqual(t, float64(2), got[0]["used"].(float64))
+		assertEqual(t, float64(498), got[0]["remaining"].(float64))
+		assertEqual(t, false, got[0]["invalid"].(bool))
+		assertEqual(t, nil, got[2]["remaining"]) // no limit
+		assertEqual(t, true, got[3]["invalid"].(bool))
+		assertEqual(t, "connection refused", got[4]["error"].(string))
+
+		if _, ok := got[0]["error"]; ok {
+			t.Error("the empty error must be omitted")
+		}
+	})
+}
+
+// quotaString returns the comparable representation of the key quota (the remaining value is a pointer, and the
+// error is checked separately).
+func quotaString(q keyQuota) string {
+	var remaining = "-"
+
+	if q.Remaining != nil {
+		remaining = strconv.FormatUint(*q.Remaining, 10)
+	}
+
+	return fmt.Sprintf("%s used=%d remaining=%s invalid=%t", q.Key, q.Used, remaining, q.Invalid)
+}
+
+func toPtr[T any](v T) *T { return &v }
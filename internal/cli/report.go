@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Supported run report formats.
+const (
+	reportFormatJSON   = "json"
+	reportFormatNDJSON = "ndjson"
+	reportFormatCSV    = "csv"
+)
+
+// reportToStdout is the report file name, which means the report is written to the standard output.
+const reportToStdout = "-"
+
+type (
+	reportFile struct {
+		Path           string `json:"path"`
+		Type           string `json:"type"`
+		OriginalSize   uint64 `json:"original_size"`
+		CompressedSize uint64 `json:"compressed_size"`
+		Status         string `json:"status"`
+		Error          string `json:"error,omitempty"`
+		Key            string `json:"key,omitempty"`
+		DuplicateOf    string `json:"duplicate_of,omitempty"`
+		DurationMs     int64  `json:"duration_ms"`
+	}
+
+	reportTotals struct {
+		Files          int    `json:"files"`
+		Compressed     int    `json:"compressed"`
+		Skipped        int    `json:"skipped"`
+		Errors         int    `json:"errors"`
+		OriginalSize   uint64 `json:"original_size"`   // total size of the successfully processed files
+		CompressedSize uint64 `json:"compressed_size"` // total size of the compressed files
+		SavedSize      int64  `json:"saved_size"`
+		DurationMs     int64  `json:"duration_ms"`
+	}
+)
+
+// newReport converts the file stats to the report entries and calculates the run totals.
+func newReport(stats []fileStat, took time.Duration) ([]reportFile, reportTotals) {
+	var (
+		files  = make([]reportFile, 0, len(stats))
+		totals = reportTotals{Files: len(stats), DurationMs: took.Milliseconds()}
+	)
+
+	for _, s := range stats {
+		var f = reportFile{
+			Path:           s.Path,
+			Type:           s.Type,
+			OriginalSize:   s.OrigSize,
+			CompressedSize: s.CompSize,
+			Status:         s.Status(),
+			Key:            maskApiKey(s.Key),
+			DuplicateOf:    s.DuplicateOf,
+			DurationMs:     s.Duration.Milliseconds(),
+		}
+
+		switch f.Status {
+		case statusCompressed:
+			totals.Compressed++
+			totals.OriginalSize += s.OrigSize
+			totals.CompressedSize += s.CompSize
+		case statusSkipped:
+			totals.Skipped++
+		case statusError:
+			totals.Errors++
+			f.Error = s.Err.Error()
+		}
+
+		files = append(files, f)
+	}
+
+	totals.SavedSize = int64(totals.OriginalSize) - int64(totals.CompressedSize) //nolint:gosec
+
+	return files, totals
+}
+
+// writeReport writes the run report in the given format to the writer.
+func writeReport(w io.Writer, format string, stats []fileStat, took time.Duration) error {
+	var files, totals = newReport(stats, took)
+
+	switch format {
+	case reportFormatJSON:
+		var enc = json.NewEncoder(w)
+
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(struct {
+			Files  []reportFile `json:"files"`
+			Totals reportTotals `json:"totals"`
+		}{Files: files, Totals: totals})
+	case reportFormatNDJSON: // one file per line, the totals are the last line
+		var enc = json.NewEncoder(w)
+
+		for _, f := range files {
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+
+		return enc.Encode(struct {
+			Totals reportTotals `json:"totals"`
+		}{Totals: totals})
+	case reportFormatCSV: // the file columns match the JSON ones, the totals are the last row with the "total" status
+		var cw = csv.NewWriter(w)
+
+		_ = cw.Write([]string{
+			"path", "type", "original_size", "compressed_size", "status", "error", "key", "duplicate_of", "duration_ms",
+			"compressed", "skipped", "errors", "saved_size", // the totals only (empty for the files)
+		})
+
+		for _, f := range files {
+			_ = cw.Write([]string{
+				f.Path,
+				f.Type,
+				strconv.FormatUint(f.OriginalSize, 10),
+				strconv.FormatUint(f.CompressedSize, 10),
+				f.Status,
+				f.Error,
+				f.Key,
+				f.DuplicateOf,
+				strconv.FormatInt(f.DurationMs, 10),
+				"", "", "", "",
+			})
+		}
+
+		_ = cw.Write([]string{
+			"",
+			"",
+			strconv.FormatUint(totals.OriginalSize, 10),
+			strconv.FormatUint(totals.CompressedSize, 10),
+			"total",
+			"",
+			"",
+			"",
+			strconv.FormatInt(totals.DurationMs, 10),
+			strconv.Itoa(totals.Compressed),
+			strconv.Itoa(totals.Skipped),
+			strconv.Itoa(totals.Errors),
+			strconv.FormatInt(totals.SavedSize, 10),
+		})
+
+		cw.Flush()
+
+		return cw.Error()
+	}
+
+	return fmt.Errorf("unsupported report format: %s", format)
+}
+
+// report writes the run reports (machine-readable and JUnit) to the files (or stdout), if they are enabled.
+func (a *App) report(stats *fileStats, took time.Duration) error {
+	if a.opt.ReportFormat != "" {
+		if err := writeReportFile(a.opt.ReportFile, func(w io.Writer) error {
+			return writeReport(w, a.opt.ReportFormat, stats.Items, took)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if a.opt.JUnitReportFile != "" {
+		if err := writeReportFile(a.opt.JUnitReportFile, func(w io.Writer) error {
+			return stats.JUnit(w, took)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeReportFile creates (or truncates) the report file and writes the report using the given function. The
+// "-" path means the standard output.
+func writeReportFile(path string, write func(io.Writer) error) error {
+	if path == "" || path == reportToStdout {
+		return write(os.Stdout)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644) //nolint:mnd
+	if err != nil {
+		return fmt.Errorf("failed to create the report file: %w", err)
+	}
+
+	if err = write(f); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("failed to write the report: %w", err)
+	}
+
+	return f.Close()
+}
+
+// maskApiKey hides the most of the API key, leaving only the first and last 4 characters visible.
+func maskApiKey(key string) string {
+	const visible = 4
+
+	if key == "" {
+		return ""
+	} else if len(key) <= visible*2 { // too short to show any part of it
+		return "…"
+	}
+
+	return key[:visible] + "…" + key[len(key)-visible:]
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportStats is the set of the file stats with all the possible statuses.
+var reportStats = []fileStat{ //nolint:gochecknoglobals
+	{
+		Path: "/img/a.png", Type: "image/png", OrigSize: 100, CompSize: 60,
+		Key: "0123456789abcdef", Duration: 1500 * time.Millisecond,
+	},
+	{Path: "/img/b.png", Type: "image/png", OrigSize: 100, CompSize: 60, DuplicateOf: "/img/a.png"},
+	{Path: "/img/c.jpg", Type: "image/jpeg", OrigSize: 50, Skipped: true},
+	{Path: "/img/d.webp", OrigSize: 70, Err: errors.New("oops")},
+}
+
+func TestNewReport(t *testing.T) {
+	t.Parallel()
+
+	var files, totals = newReport(reportStats, 3*time.Second)
+
+	assertEqual(t, 4, len(files))
+
+	assertEqual(t, reportFile{
+		Path: "/img/a.png", Type: "image/png", OriginalSize: 100, CompressedSize: 60, Status: statusCompressed,
+		Key: "0123…cdef", DurationMs: 1500,
+	}, files[0])
+	assertEqual(t, reportFile{
+		Path: "/img/b.png", Type: "image/png", OriginalSize: 100, CompressedSize: 60, Status: statusCompressed,
+		DuplicateOf: "/img/a.png",
+	}, files[1])
+	assertEqual(t, statusSkipped, files[2].Status)
+	assertEqual(t, reportFile{Path: "/img/d.webp", OriginalSize: 70, Status: statusError, Error: "oops"}, files[3])
+
+	assertEqual(t, reportTotals{
+		Files:          4,
+		Compressed:     2,
+		Skipped:        1,
+		Errors:         1,
+		OriginalSize:   200, // only the compressed files are counted
+		CompressedSize: 120,
+		SavedSize:      80,
+		DurationMs:     3000,
+	}, totals)
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveFormat string
+		check      func(t *testing.T, out string)
+	}{
+		reportFormatJSON: {
+			giveFormat: reportFormatJSON,
+			check: func(t *testing.T, out string) {
+				var got struct {
+					Files  []reportFile `json:"files"`
+					Totals reportTotals `json:"totals"`
+				}
+
+				assertNoError(t, json.Unmarshal([]byte(out), &got))
+				assertEqual(t, 4, len(got.Files))
+				assertEqual(t, "/img/a.png", got.Files[1].DuplicateOf)
+				assertEqual(t, "oops", got.Files[3].Error)
+				assertEqual(t, 2, got.Totals.Compressed)
+				assertEqual(t, int64(80), got.Totals.SavedSize)
+			},
+		},
+		reportFormatNDJSON: {
+			giveFormat: reportFormatNDJSON,
+			check: func(t *testing.T, out string) {
+				var lines = strings.Split(strings.TrimSpace(out), "\n")
+
+				assertEqual(t, 5, len(lines)) // 4 files and the totals
+
+				var file reportFile
+
+				assertNoError(t, json.Unmarshal([]byte(lines[1]), &file))
+				assertEqual(t, "/img/b.png", file.Path)
+				assertEqual(t, "/img/a.png", file.DuplicateOf)
+
+				var last struct {
+					Totals reportTotals `json:"totals"`
+				}
+
+				assertNoError(t, json.Unmarshal([]byte(lines[4]), &last))
+				assertEqual(t, 4, last.Totals.Files)
+				assertEqual(t, 1, last.Totals.Errors)
+			},
+		},
+		reportFormatCSV: {
+			giveFormat: reportFormatCSV,
+			check: func(t *testing.T, out string) {
+				rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				assertNoError(t, err)
+
+				assertEqual(t, 6, len(rows)) // the header, 4 files and the totals
+				assertEqual(t,
+					"path,type,original_size,compressed_size,status,error,key,duplicate_of,duration_ms,"+
+						"compressed,skipped,errors,saved_size",
+					strings.Join(rows[0], ","),
+				)
+				assertEqual(t, "/img/a.png,image/png,100,60,compressed,,0123…cdef,,1500,,,,", strings.Join(rows[1], ","))
+				assertEqual(t, "/img/b.png,image/png,100,60,compressed,,,/img/a.png,0,,,,", strings.Join(rows[2], ","))
+				assertEqual(t, "/img/d.webp,,70,0,error,oops,,,0,,,,", strings.Join(rows[4], ","))
+				assertEqual(t, ",,200,120,total,,,,3000,2,1,1,80", strings.Join(rows[5], ","))
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			assertNoError(t, writeReport(&buf, tc.giveFormat, reportStats, 3*time.Second))
+
+			tc.check(t, buf.String())
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		t.Parallel()
+
+		assertError(t, writeReport(&bytes.Buffer{}, "xml", reportStats, time.Second))
+	})
+}
+
+func TestMaskApiKey(t *testing.T) {
+	t.Parallel()
+
+	for give, want := range map[string]string{
+		"":                 "",
+		"short":            "…",
+		"12345678":         "…",
+		"123456789":        "1234…6789",
+		"0123456789abcdef": "0123…cdef",
+	} {
+		t.Run(give, func(t *testing.T) {
+			t.Parallel()
+
+			assertEqual(t, want, maskApiKey(give))
+		})
+	}
+}
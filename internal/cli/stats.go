@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"encoding/xml"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"gh.tarampamp.am/tinifier/v5/internal/humanize"
@@ -14,6 +17,47 @@ type fileStat struct {
 	Path, Type         string
 	OrigSize, CompSize uint64
 	Skipped            bool
+	SkipReason         skipReason    // why the file was skipped (set along with the Skipped flag)
+	DryRun             bool          // the file was not processed, only found (the compressed size is unknown)
+	Cached             bool          // the file was skipped because it's found in the cache (already compressed)
+	Err                error         // the error occurred during the file processing (if any)
+	Key                string        // the API key used to process the file (empty if no key was used)
+	Duration           time.Duration // the time spent on the file processing
+	DuplicateOf        string        // path of the file with the same content, whose compression result was reused
+}
+
+// skipReason describes why the file was skipped.
+type skipReason = string
+
+const (
+	skipReasonCached       skipReason = "already compressed (cached)"
+	skipReasonNotWorth     skipReason = "compression is not worth it"
+	skipReasonOutputExists skipReason = "output file already exists"
+	skipReasonHardLink     skipReason = "hard link to the already compressed file"
+)
+
+// fileStatus is the final status of the file processing.
+type fileStatus = string
+
+const (
+	statusCompressed fileStatus = "compressed"
+	statusSkipped    fileStatus = "skipped"
+	statusError      fileStatus = "error"
+	statusDryRun     fileStatus = "dry-run"
+)
+
+// Status returns the final status of the file processing.
+func (s fileStat) Status() fileStatus {
+	switch {
+	case s.Err != nil:
+		return statusError
+	case s.DryRun:
+		return statusDryRun
+	case s.Skipped:
+		return statusSkipped
+	default:
+		return statusCompressed
+	}
 }
 
 type fileStats struct {
@@ -27,13 +71,28 @@ func (fs *fileStats) Add(v fileStat) {
 	fs.mu.Unlock()
 }
 
-func (fs *fileStats) Table() string { //nolint:funlen
+// Duplicates returns the number of the duplicate files, which reused the compression results (or would reuse,
+// in the dry run mode) instead of spending the API compressions.
+func (fs *fileStats) Duplicates() (n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, item := range fs.Items {
+		if item.DuplicateOf != "" && item.Err == nil && !item.Cached {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (fs *fileStats) Table() string { //nolint:funlen,gocyclo
 	if len(fs.Items) == 0 {
 		return ""
 	}
 
 	var (
-		columns = make(map[string][4]string, len(fs.Items))
+		rows = make([][4]string, 0, len(fs.Items)) // the same path may be listed several times (e.g. conversions)
 
 		longestFileName int
 		longestType     int
@@ -42,6 +101,8 @@ func (fs *fileStats) Table() string { //nolint:funlen
 		totalOrig    int
 		totalComp    int
 		totalSkipped int
+		totalDryRun  int
+		totalDryOrig int // total size of the files found in the dry run mode
 	)
 
 	for _, item := range fs.Items {
@@ -58,6 +119,18 @@ func (fs *fileStats) Table() string { //nolint:funlen
 			)
 		)
 
+		if item.DuplicateOf != "" {
+			deltaSize += ", duplicate"
+		}
+
+		if item.DryRun {
+			diffSize, deltaSize = humanize.Bytes(item.OrigSize), ""
+		}
+
+		if item.Err != nil {
+			diffSize, deltaSize = humanize.Bytes(item.OrigSize), ""
+		}
+
 		if v := utf8.RuneCountInString(fileName); v > longestFileName {
 			longestFileName = v
 		}
@@ -70,14 +143,21 @@ func (fs *fileStats) Table() string { //nolint:funlen
 			longestDiffSize = v
 		}
 
-		totalOrig += int(item.OrigSize) //nolint:gosec
-		totalComp += int(item.CompSize) //nolint:gosec
+		if item.Err == nil && !item.Skipped && !item.DryRun { // only the compressed files, as in the run report
+			totalOrig += int(item.OrigSize) //nolint:gosec
+			totalComp += int(item.CompSize) //nolint:gosec
+		}
 
-		if item.Skipped {
+		if item.Skipped || item.Err != nil {
 			totalSkipped++
 		}
 
-		columns[item.Path] = [4]string{fileName, typeName, diffSize, deltaSize}
+		if item.DryRun {
+			totalDryRun++
+			totalDryOrig += int(item.OrigSize) //nolint:gosec
+		}
+
+		rows = append(rows, [4]string{fileName, typeName, diffSize, deltaSize})
 	}
 
 	var b strings.Builder
@@ -89,15 +169,18 @@ func (fs *fileStats) Table() string { //nolint:funlen
 	for i, item := range fs.Items {
 		b.WriteRune(' ')
 
-		if item.Skipped {
+		switch {
+		case item.DryRun:
+			b.WriteRune('•')
+		case item.Skipped, item.Err != nil:
 			b.WriteRune('✘')
-		} else {
+		default:
 			b.WriteRune('✔')
 		}
 
 		b.WriteRune(' ')
 
-		row := columns[item.Path]
+		row := rows[i]
 		fileName, typeName, diffSize, deltaSize := row[0], row[1], row[2], row[3]
 
 		b.WriteString(fileName)
@@ -108,7 +191,23 @@ func (fs *fileStats) Table() string { //nolint:funlen
 		b.WriteString(strings.Repeat(" ", max(0, longestType-utf8.RuneCountInString(typeName))))
 		b.WriteString(pad)
 
-		if !item.Skipped {
+		switch {
+		case item.DryRun:
+			b.WriteString(diffSize)
+			b.WriteString(strings.Repeat(" ", max(0, longestDiffSize-utf8.RuneCountInString(diffSize))))
+			b.WriteString(pad)
+
+			if item.DuplicateOf != "" {
+				b.WriteString("(dry run, duplicate)")
+			} else {
+				b.WriteString("(dry run)")
+			}
+		case item.Err != nil:
+			b.WriteString(diffSize)
+			b.WriteString(strings.Repeat(" ", max(0, longestDiffSize-utf8.RuneCountInString(diffSize))))
+			b.WriteString(pad)
+			b.WriteString("(error)")
+		case !item.Skipped:
 			b.WriteString(diffSize)
 			b.WriteString(strings.Repeat(" ", max(0, longestDiffSize-utf8.RuneCountInString(diffSize))))
 			b.WriteString(pad)
@@ -116,7 +215,9 @@ func (fs *fileStats) Table() string { //nolint:funlen
 			b.WriteRune('(')
 			b.WriteString(deltaSize)
 			b.WriteRune(')')
-		} else {
+		case item.Cached:
+			b.WriteString("(skipped, cached)")
+		default:
 			b.WriteString("(skipped)")
 		}
 
@@ -137,6 +238,12 @@ func (fs *fileStats) Table() string { //nolint:funlen
 		b.WriteString(strings.Repeat(" ", max(0, longestType-utf8.RuneCountInString(total))))
 		b.WriteString(pad)
 
+		if totalDryRun > 0 { // in the dry run mode, only the total size of the files to process is known
+			b.WriteString(humanize.Bytes(totalDryOrig))
+
+			return b.String()
+		}
+
 		var (
 			diffSize = fmt.Sprintf("%s → %s",
 				humanize.Bytes(totalOrig),
@@ -159,3 +266,88 @@ func (fs *fileStats) Table() string { //nolint:funlen
 
 	return b.String()
 }
+
+type (
+	junitTestSuite struct {
+		XMLName  xml.Name        `xml:"testsuite"`
+		Name     string          `xml:"name,attr"`
+		Tests    int             `xml:"tests,attr"`
+		Failures int             `xml:"failures,attr"`
+		Skipped  int             `xml:"skipped,attr"`
+		Time     string          `xml:"time,attr"`
+		Cases    []junitTestCase `xml:"testcase"`
+	}
+
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+		Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+
+	junitSkipped struct {
+		Message string `xml:"message,attr,omitempty"`
+	}
+)
+
+// JUnit writes the stats as a JUnit XML report, where each file is a test case. Failed files are reported as
+// failures (with the wrapped error text), and skipped files (including the dry run ones) as skipped test cases.
+func (fs *fileStats) JUnit(w io.Writer, took time.Duration) error {
+	var suite = junitTestSuite{
+		Name:  "tinifier",
+		Tests: len(fs.Items),
+		Time:  junitSeconds(took),
+		Cases: make([]junitTestCase, 0, len(fs.Items)),
+	}
+
+	for _, item := range fs.Items {
+		var tc = junitTestCase{
+			Name:      item.Path,
+			ClassName: item.Type,
+			Time:      junitSeconds(item.Duration),
+		}
+
+		if tc.ClassName == "" { // the type is unknown if the file was not uploaded
+			tc.ClassName, _ = convertMimeType(strings.TrimPrefix(filepath.Ext(item.Path), "."))
+		}
+
+		switch {
+		case item.Err != nil:
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: item.Err.Error(), Text: item.Err.Error()}
+		case item.DryRun:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: "dry run"}
+		case item.Skipped:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: item.SkipReason}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	var enc = xml.NewEncoder(w)
+
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// junitSeconds formats the duration as seconds with milliseconds precision, as JUnit expects.
+func junitSeconds(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStats_Table(t *testing.T) {
+	t.Parallel()
+
+	t.Run("same path, several formats", func(t *testing.T) {
+		t.Parallel()
+
+		var stats fileStats
+
+		stats.Add(fileStat{Path: "/img/a.png", Type: "image/webp", OrigSize: 100, Err: errors.New("oops")})
+		stats.Add(fileStat{Path: "/img/a.png", Type: "image/avif", OrigSize: 100, Skipped: true})
+		stats.Add(fileStat{Path: "/img/a.png", Type: "image/jpeg", OrigSize: 100, CompSize: 50})
+
+		var lines = strings.Split(stats.Table(), "\n")
+
+		assertEqual(t, 4, len(lines)) // 3 rows and the total
+		assertContains(t, lines[0], "image/webp")
+		assertContains(t, lines[0], "(error)")
+		assertContains(t, lines[1], "image/avif")
+		assertContains(t, lines[1], "(skipped)")
+		assertContains(t, lines[2], "image/jpeg")
+		assertContains(t, lines[2], "-50 B, -50.00%")
+		assertContains(t, lines[3], "Total:")
+	})
+
+	t.Run("skipped files are not counted in the total", func(t *testing.T) {
+		t.Parallel()
+
+		var stats fileStats
+
+		stats.Add(fileStat{Path: "/img/a.png", Type: "image/png", OrigSize: 3000, Skipped: true, Cached: true})
+		stats.Add(fileStat{Path: "/img/b.png", Type: "image/png", OrigSize: 2000, Skipped: true})
+		stats.Add(fileStat{Path: "/img/c.png", Type: "image/png", OrigSize: 1000, CompSize: 600})
+		stats.Add(fileStat{Path: "/img/d.png", OrigSize: 500, Err: errors.New("oops")})
+
+		var lines = strings.Split(stats.Table(), "\n")
+
+		assertEqual(t, 5, len(lines))
+		assertContains(t, lines[0], "(skipped, cached)")
+		assertContains(t, lines[4], "Total:")
+		assertContains(t, lines[4], "1000 B → 600 B")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+
+		var stats fileStats
+
+		assertEqual(t, "", stats.Table())
+	})
+}
+
+func TestFileStats_JUnit(t *testing.T) {
+	t.Parallel()
+
+	var stats fileStats
+
+	stats.Add(fileStat{Path: "/img/a.png", Type: "image/png", CompSize: 50, Duration: 1500 * time.Millisecond})
+	stats.Add(fileStat{Path: "/img/b.png", Err: errors.New("oops")})
+	stats.Add(fileStat{Path: "/img/c.jpg", DryRun: true})
+	stats.Add(fileStat{Path: "/img/d.png", Skipped: true, Cached: true, SkipReason: skipReasonCached})
+	stats.Add(fileStat{Path: "/img/e.png", Type: "image/png", Skipped: true, SkipReason: skipReasonNotWorth})
+	stats.Add(fileStat{Path: "/img/f.webp", Skipped: true, SkipReason: skipReasonOutputExists})
+
+	var buf bytes.Buffer
+
+	assertNoError(t, stats.JUnit(&buf, 3*time.Second))
+	assertContains(t, buf.String(), xml.Header)
+
+	var got junitTestSuite
+
+	assertNoError(t, xml.Unmarshal(buf.Bytes(), &got))
+
+	assertEqual(t, "tinifier", got.Name)
+	assertEqual(t, 6, got.Tests)
+	assertEqual(t, 1, got.Failures)
+	assertEqual(t, 4, got.Skipped)
+	assertEqual(t, "3.000", got.Time)
+	assertEqual(t, 6, len(got.Cases))
+
+	for i, want := range []struct {
+		name, class, time string
+		failure, skipped  string // empty means no element
+	}{
+		{name: "/img/a.png", class: "image/png", time: "1.500"},
+		{name: "/img/b.png", class: "image/png", time: "0.000", failure: "oops"},
+		{name: "/img/c.jpg", class: "image/jpeg", time: "0.000", skipped: "dry run"},
+		{name: "/img/d.png", class: "image/png", time: "0.000", skipped: skipReasonCached},
+		{name: "/img/e.png", class: "image/png", time: "0.000", skipped: skipReasonNotWorth},
+		{name: "/img/f.webp", class: "image/webp", time: "0.000", skipped: skipReasonOutputExists},
+	} {
+		var tc = got.Cases[i]
+
+		assertEqual(t, want.name, tc.Name)
+		assertEqual(t, want.class, tc.ClassName)
+		assertEqual(t, want.time, tc.Time)
+
+		if want.failure != "" {
+			if tc.Failure == nil {
+				t.Fatalf("%s: expected the failure element", want.name)
+			}
+
+			assertEqual(t, want.failure, tc.Failure.Message)
+			assertEqual(t, want.failure, tc.Failure.Text)
+		} else if tc.Failure != nil {
+			t.Errorf("%s: unexpected failure element", want.name)
+		}
+
+		if want.skipped != "" {
+			if tc.Skipped == nil {
+				t.Fatalf("%s: expected the skipped element", want.name)
+			}
+
+			assertEqual(t, want.skipped, tc.Skipped.Message)
+		} else if tc.Skipped != nil {
+			t.Errorf("%s: unexpected skipped element", want.name)
+		}
+	}
+}
+
+func assertEqual[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+
+	if expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func assertContains(t *testing.T, s, substr string) {
+	t.Helper()
+
+	if !strings.Contains(s, substr) {
+		t.Errorf("expected %q to contain %q", s, substr)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gh.tarampamp.am/tinifier/v5/internal/humanize"
+	"gh.tarampamp.am/tinifier/v5/internal/retry"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+)
+
+// stdinPath is the special path, which means the image is read from the standard input (and the compressed one
+// is written to the standard output).
+const stdinPath = "-"
+
+// compressStdin reads the image from the stdin, compresses it and writes the result to the stdout. All the logs
+// are written to the stderr, so the stdout stays binary-clean and can be piped to other tools.
+func (a *App) compressStdin(ctx context.Context, in io.Reader, out io.Writer) error { //nolint:funlen
+	switch {
+	case a.opt.DryRun:
+		return errors.New("dry run is not supported when reading from stdin")
+	case a.opt.Store.Service != "":
+		return errors.New("storing in the cloud is not supported when reading from stdin")
+	case a.opt.OutputDir != "":
+		return errors.New("output directory is not supported when reading from stdin")
+	case len(a.opt.ConvertTo) > 0:
+		return errors.New("conversion is not supported when reading from stdin")
+	case a.opt.ReportFormat != "" || a.opt.JUnitReportFile != "":
+		return errors.New("reports are not supported when reading from stdin")
+	}
+
+	orig, readErr := io.ReadAll(in)
+	if readErr != nil {
+		return fmt.Errorf("failed to read stdin: %w", readErr)
+	}
+
+	if len(orig) == 0 {
+		return errors.New("no data in stdin")
+	}
+
+	var (
+		pool  = a.newClientsPool()
+		comp  *tinypng.Compressed
+		lease *tinypng.Lease // the key used to upload the image, the download uses the same key
+	)
+
+	for { // attempt uploading with the key rotation if necessary
+		var leaseErr error
+
+		if lease, leaseErr = pool.Acquire(ctx); leaseErr != nil {
+			return leaseErr
+		}
+
+		var uErr error
+
+		comp, uErr = a.uploadBytes(ctx, orig, lease.Client)
+		if uErr != nil {
+			if classifyError(uErr) == errActionRotate {
+				lease.Fail(uErr)
+
+				continue
+			}
+
+			lease.Release()
+
+			return fmt.Errorf("failed to upload: %w", uErr)
+		}
+
+		defer lease.Release() // the key is in use until the image is downloaded
+
+		break
+	}
+
+	var (
+		origSize = uint64(len(orig))
+		// the original image is written back if it cannot be compressed (enough), the same way as the files are
+		// skipped (the resized image is always used, since its size is known only after downloading)
+		keepOrig = a.opt.ResizeMethod == "" && (comp.Size == 0 || comp.Size >= origSize ||
+			((float64(origSize)-float64(comp.Size))/float64(comp.Size))*100 < a.opt.SkipIfDiffLessThan)
+		result = orig
+	)
+
+	if !keepOrig {
+		var buf bytes.Buffer
+
+		if err := retry.Try(
+			ctx,
+			a.opt.RetryAttempts,
+			func(context.Context, uint) error {
+				buf.Reset()
+
+				return comp.Download(ctx, &buf, a.downloadOptions()...)
+			},
+			a.apiRetryOptions()...,
+		); err != nil {
+			if classifyError(err) == errActionRotate {
+				lease.Fail(err) // retire the unauthorized key, or put the rate-limited one on the cool-down
+			}
+
+			return fmt.Errorf("failed to download the compressed image: %w", err)
+		}
+
+		result = buf.Bytes()
+	}
+
+	if _, err := out.Write(result); err != nil {
+		return fmt.Errorf("failed to write the result: %w", err)
+	}
+
+	var resultSize = uint64(len(result))
+
+	a.errorf("Image from stdin compressed (%s → %s / %s, %s)",
+		humanize.Bytes(origSize),
+		humanize.Bytes(resultSize),
+		humanize.BytesDiff(resultSize, origSize),
+		humanize.PercentageDiff(resultSize, origSize),
+	)
+
+	return nil
+}
+
+// uploadBytes uploads the image content to the tinypng.com (the same as uploadFile, but for the in-memory data).
+func (a *App) uploadBytes(ctx context.Context, data []byte, c *tinypng.Client) (res *tinypng.Compressed, _ error) {
+	return res, retry.Try(
+		ctx,
+		a.opt.RetryAttempts,
+		func(context.Context, uint) (err error) {
+			res, err = c.Compress(ctx, bytes.NewReader(data))
+
+			return err
+		},
+		a.apiRetryOptions()...,
+	)
+}
+
+// stdinRequested reports whether the image should be read from the stdin (the only argument is "-").
+func stdinRequested(args []string) bool { return len(args) == 1 && args[0] == stdinPath }
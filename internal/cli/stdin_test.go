@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
+)
+
+func TestApp_CompressStdin(t *testing.T) {
+	t.Parallel()
+
+	var imagePath = filepath.Join(t.TempDir(), "image.png")
+
+	writeImage(t, imagePath, 1)
+
+	orig, err := os.ReadFile(imagePath)
+	assertNoError(t, err)
+
+	for name, tc := range map[string]struct {
+		giveRatio float64
+		giveArgs  []string
+		want      []byte
+	}{
+		"compressed": {
+			giveRatio: 0.5,
+			want:      orig[:len(orig)/2], // the fake server truncates the image to the ratio
+		},
+		"not smaller": {
+			giveRatio: 1,
+			want:      orig,
+		},
+		"diff is less than required": {
+			giveRatio: 0.9,
+			giveArgs:  []string{"--skip-if-diff-less", "50"},
+			want:      orig,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var srv = tinypngtest.NewServer(tinypngtest.WithCompressionRatio(tc.giveRatio))
+
+			t.Cleanup(srv.Close)
+
+			stdout, stderr, runErr := runStdinApp(t, orig,
+				append([]string{"--api-key", "any-key", "--api-url", srv.URL}, tc.giveArgs...)...,
+			)
+
+			assertNoError(t, runErr)
+
+			if !bytes.Equal(tc.want, stdout) {
+				t.Errorf("expected %d bytes in stdout, got %d", len(tc.want), len(stdout))
+			}
+
+			if !strings.Contains(stderr, "Image from stdin compressed") {
+				t.Errorf("expected the stats in stderr, got %q", stderr)
+			}
+
+			assertEqual(t, uint64(1), srv.UsedQuota("any-key"))
+		})
+	}
+
+	t.Run("unsupported options", func(t *testing.T) {
+		t.Parallel()
+
+		var srv = tinypngtest.NewServer()
+
+		t.Cleanup(srv.Close)
+
+		for name, args := range map[string][]string{
+			"output dir":    {"--output-dir", t.TempDir()},
+			"report":        {"--report", reportFormatJSON, "--report-file", reportToStdout},
+			"junit report":  {"--junit-report", reportToStdout},
+			"conversion":    {"--convert-to", "webp"},
+			"cloud storage": {"--store-service", "gcs", "--store-path", "bucket/{name}", "--store-gcs-access-token", "x"},
+			"dry run":       {"--dry-run"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				stdout, _, runErr := runStdinApp(t, orig,
+					append([]string{"--api-key", "key-" + name, "--api-url", srv.URL}, args...)...,
+				)
+
+				assertError(t, runErr)
+
+				if !strings.Contains(runErr.Error(), "not supported when reading from stdin") {
+					t.Errorf("unexpected error: %v", runErr)
+				}
+
+				assertEqual(t, 0, len(stdout))
+				assertEqual(t, uint64(0), srv.UsedQuota("key-"+name)) // rejected before the upload
+			})
+		}
+	})
+
+	t.Run("empty stdin", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, runErr := runStdinApp(t, nil, "--api-key", "any-key")
+
+		assertError(t, runErr)
+	})
+}
+
+// runStdinApp runs the application in the stdin mode with the given stdin content, and returns the stdout and
+// stderr content.
+func runStdinApp(t *testing.T, stdin []byte, args ...string) ([]byte, string, error) {
+	t.Helper()
+
+	var (
+		app            = NewApp("tinifier")
+		stdout, stderr bytes.Buffer
+		config         = filepath.Join(t.TempDir(), "missing-config.yml")
+	)
+
+	app.stdin, app.stdout, app.stderr = bytes.NewReader(stdin), &stdout, &stderr
+
+	var err = app.Run(t.Context(), append(append([]string{"--config-file", config}, args...), stdinPath))
+
+	return stdout.Bytes(), stderr.String(), err
+}
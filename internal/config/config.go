@@ -10,10 +10,36 @@ import (
 )
 
 type (
-	// Config is used to unmarshal the configuration file content.
+	// Config is used to unmarshal the configuration file content. Only the long-lived settings are here - the
+	// options changing the result of a particular run (resizing, conversion, output directory, cloud storage,
+	// dry run, etc.) are set using the command-line flags only, so they are never applied by accident.
 	Config struct {
 		// pointers are used to distinguish between unset and set values (nil = unset)
-		ApiKeys *[]string `yaml:"apiKeys"`
+		ApiKeys             *[]string `yaml:"apiKeys"`
+		ApiURL              *string   `yaml:"apiUrl"`
+		KeyQuotaLimit       *uint64   `yaml:"keyQuotaLimit"`
+		KeysStrategy        *string   `yaml:"keysStrategy"` // e.g. "round-robin"
+		MaxInFlightPerKey   *uint     `yaml:"maxInFlightPerKey"`
+		KeyCoolDown         *string   `yaml:"keyCoolDown"` // duration, e.g. "30s"
+		DetectByContent     *bool     `yaml:"detectByContent"`
+		Include             *[]string `yaml:"include"` // glob patterns, e.g. "**/*.png"
+		Exclude             *[]string `yaml:"exclude"`
+		UseGitignore        *bool     `yaml:"useGitignore"`
+		Hidden              *bool     `yaml:"hidden"`
+		FollowSymlinks      *bool     `yaml:"followSymlinks"`
+		MinSize             *string   `yaml:"minSize"`   // e.g. "10KB"
+		MaxSize             *string   `yaml:"maxSize"`   // e.g. "5MB"
+		NewerThan           *string   `yaml:"newerThan"` // duration (e.g. "72h" or "7d") or date (e.g. "2025-01-31")
+		OlderThan           *string   `yaml:"olderThan"` // the same format as for NewerThan
+		RetryAttempts       *uint     `yaml:"retryAttempts"`
+		DelayBetweenRetries *string   `yaml:"delayBetweenRetries"` // duration, e.g. "1s"
+		RetryBackoff        *string   `yaml:"retryBackoff"`        // "fixed" or "exponential"
+		RetryMaxDelay       *string   `yaml:"retryMaxDelay"`       // duration, e.g. "30s"
+		RetryJitter         *bool     `yaml:"retryJitter"`
+		CacheFile           *string   `yaml:"cacheFile"`
+		Report              *string   `yaml:"report"` // report format, e.g. "json"
+		ReportFile          *string   `yaml:"reportFile"`
+		JUnitReport         *string   `yaml:"junitReport"` // path to the JUnit report file
 	}
 )
 
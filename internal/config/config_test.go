@@ -24,9 +24,57 @@ func TestConfig_FromFile(t *testing.T) {
 		},
 		"full config": {
 			giveContent: `
-apiKeys: [foo, bar, baz]`,
+apiKeys: [foo, bar, baz]
+apiUrl: http://127.0.0.1:8080
+minSize: 1024
+maxSize: 5MB
+newerThan: 7d
+olderThan: 2025-01-31
+keyQuotaLimit: 500
+keysStrategy: round-robin
+maxInFlightPerKey: 2
+keyCoolDown: 1m
+detectByContent: true
+include: ["**/*.png", "assets/**"]
+exclude: [vendor/**]
+useGitignore: true
+hidden: false
+followSymlinks: true
+retryAttempts: 5
+delayBetweenRetries: 2s
+retryBackoff: exponential
+retryMaxDelay: 30s
+retryJitter: true
+cacheFile: /tmp/tinifier.cache
+report: json
+reportFile: report.json
+junitReport: junit.xml`,
 			wantStruct: func() (c config.Config) {
 				c.ApiKeys = toPtr([]string{"foo", "bar", "baz"})
+				c.ApiURL = toPtr("http://127.0.0.1:8080")
+				c.MinSize = toPtr("1024")
+				c.MaxSize = toPtr("5MB")
+				c.NewerThan = toPtr("7d")
+				c.OlderThan = toPtr("2025-01-31")
+				c.KeyQuotaLimit = toPtr(uint64(500))
+				c.KeysStrategy = toPtr("round-robin")
+				c.MaxInFlightPerKey = toPtr(uint(2))
+				c.KeyCoolDown = toPtr("1m")
+				c.DetectByContent = toPtr(true)
+				c.Include = toPtr([]string{"**/*.png", "assets/**"})
+				c.Exclude = toPtr([]string{"vendor/**"})
+				c.UseGitignore = toPtr(true)
+				c.Hidden = toPtr(false)
+				c.FollowSymlinks = toPtr(true)
+				c.RetryAttempts = toPtr(uint(5))
+				c.DelayBetweenRetries = toPtr("2s")
+				c.RetryBackoff = toPtr("exponential")
+				c.RetryMaxDelay = toPtr("30s")
+				c.RetryJitter = toPtr(true)
+				c.CacheFile = toPtr("/tmp/tinifier.cache")
+				c.Report = toPtr("json")
+				c.ReportFile = toPtr("report.json")
+				c.JUnitReport = toPtr("junit.xml")
 
 				return
 			}(),
@@ -0,0 +1,89 @@
+package finder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// DiagnosticKind is the kind of the problem with the path, found during the search.
+type DiagnosticKind byte
+
+const (
+	DiagnosticUnreadable       DiagnosticKind = iota // the path can't be read (an I/O error)
+	DiagnosticNotFound                               // the path does not exist
+	DiagnosticPermissionDenied                       // no permission to access the path
+	DiagnosticUnresolvable                           // the path (or the symlink) can't be resolved
+)
+
+// String returns a human-readable representation of the diagnostic kind.
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagnosticNotFound:
+		return "not found"
+	case DiagnosticPermissionDenied:
+		return "permission denied"
+	case DiagnosticUnresolvable:
+		return "unresolvable"
+	case DiagnosticUnreadable:
+		return "unreadable"
+	}
+
+	return fmt.Sprintf("DiagnosticKind(%d)", k)
+}
+
+// Diagnostic describes the path, skipped during the search because of the error.
+type Diagnostic struct {
+	Path string // the skipped path
+	Kind DiagnosticKind
+	Err  error // the original error
+}
+
+// newDiagnostic creates the diagnostic for the path, detecting the kind by the error.
+func newDiagnostic(path string, err error) Diagnostic {
+	var d = Diagnostic{Path: path, Kind: DiagnosticUnreadable, Err: err}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		d.Kind = DiagnosticNotFound
+
+		if _, lErr := os.Lstat(path); lErr == nil {
+			d.Kind = DiagnosticUnresolvable // the broken symlink exists, but its target does not
+		}
+	case errors.Is(err, fs.ErrPermission):
+		d.Kind = DiagnosticPermissionDenied
+	}
+
+	return d
+}
+
+// Error implements the error interface, so the diagnostic can be reported as an error.
+func (d Diagnostic) Error() string {
+	var cause = d.Err
+
+	if pathErr, ok := errors.AsType[*fs.PathError](cause); ok {
+		cause = pathErr.Err // the path is already in the message
+	}
+
+	if cause == nil {
+		return fmt.Sprintf("%s (%s)", d.Path, d.Kind)
+	}
+
+	return fmt.Sprintf("%s (%s): %s", d.Path, d.Kind, cause)
+}
+
+// Unwrap returns the original error.
+func (d Diagnostic) Unwrap() error { return d.Err }
+
+// WithDiagnostics sets the function, called for every path skipped because of the error (e.g. the given path
+// does not exist, or the nested directory can't be read). The given paths are checked once the search is started
+// (when Files is called), the rest - while iterating over the sequence (so, on every iteration).
+func WithDiagnostics(fn func(Diagnostic)) Option { return func(o *options) { o.OnDiagnostic = fn } }
+
+// diagnose reports the problem with the path, if the diagnostics function is set.
+func (o options) diagnose(d Diagnostic) {
+	if o.OnDiagnostic != nil {
+		o.OnDiagnostic(d)
+	}
+}
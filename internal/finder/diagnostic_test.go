@@ -0,0 +1,96 @@
+package finder_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/internal/finder"
+)
+
+func TestWithDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	var tmpDir = t.TempDir()
+
+	assertNoError(t, os.MkdirAll(filepath.Join(tmpDir, "dir"), 0o755))
+	assertNoError(t, os.WriteFile(filepath.Join(tmpDir, "dir", "a.png"), nil, 0o600))
+
+	if err := os.Symlink("missing.png", filepath.Join(tmpDir, "dir", "broken.png")); err != nil {
+		t.Skipf("symlinks are not supported: %s", err)
+	}
+
+	for name, recursive := range map[string]bool{"recursive": true, "non-recursive": false} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				missing = filepath.Join(tmpDir, "missing")
+				broken  = filepath.Join(tmpDir, "dir", "broken.png")
+				got     []finder.Diagnostic
+			)
+
+			var files = slices.Collect(finder.Files(t.Context(), []string{missing, filepath.Join(tmpDir, "dir")},
+				finder.WithRecursive(recursive),
+				finder.WithDiagnostics(func(d finder.Diagnostic) { got = append(got, d) }),
+			))
+
+			assertSlicesEqual(t, []string{filepath.Join(tmpDir, "dir", "a.png")}, files)
+
+			if len(got) != 2 {
+				t.Fatalf("expected 2 diagnostics, got %d: %v", len(got), got)
+			}
+
+			assertEqual(t, missing, got[0].Path)
+			assertEqual(t, finder.DiagnosticNotFound, got[0].Kind)
+			assertEqual(t, true, errors.Is(got[0], fs.ErrNotExist))
+			assertEqual(t, missing+" (not found): no such file or directory", got[0].Error())
+
+			assertEqual(t, broken, got[1].Path)
+			assertEqual(t, finder.DiagnosticUnresolvable, got[1].Kind)
+		})
+	}
+
+	t.Run("permission denied", func(t *testing.T) {
+		t.Parallel()
+
+		if os.Geteuid() == 0 {
+			t.Skip("the permissions are not checked for the root user")
+		}
+
+		var dir = filepath.Join(t.TempDir(), "locked")
+
+		assertNoError(t, os.MkdirAll(dir, 0o000))
+
+		var got []finder.Diagnostic
+
+		for range finder.Files(t.Context(), []string{dir},
+			finder.WithDiagnostics(func(d finder.Diagnostic) { got = append(got, d) }),
+		) {
+			t.Error("no files expected")
+		}
+
+		if len(got) != 1 {
+			t.Fatalf("expected 1 diagnostic, got %d: %v", len(got), got)
+		}
+
+		assertEqual(t, finder.DiagnosticPermissionDenied, got[0].Kind)
+	})
+}
+
+func TestDiagnosticKind_String(t *testing.T) {
+	t.Parallel()
+
+	for kind, want := range map[finder.DiagnosticKind]string{
+		finder.DiagnosticUnreadable:       "unreadable",
+		finder.DiagnosticNotFound:         "not found",
+		finder.DiagnosticPermissionDenied: "permission denied",
+		finder.DiagnosticUnresolvable:     "unresolvable",
+		finder.DiagnosticKind(42):         "DiagnosticKind(42)",
+	} {
+		assertEqual(t, want, kind.String())
+	}
+}
@@ -8,12 +8,74 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
+
+	"gh.tarampamp.am/tinifier/v5/internal/validate"
+)
+
+type (
+	// Entry is a file (or a directory) found during the search. It embeds the fs.FileInfo, so the file name,
+	// size, mode, etc. are available directly.
+	Entry struct {
+		fs.FileInfo
+		Path    string // absolute path
+		RelPath string // slash-separated path, relative to the searched directory (e.g. "dir/file.png")
+	}
+
+	// FileFilterFn is a function type used to filter files (or directories, see WithDirFilter).
+	// It takes an Entry and returns a boolean indicating whether the file should be included (true)
+	// or filtered out (false).
+	FileFilterFn = func(Entry) bool
+
+	options struct {
+		Recursive      bool             // search in the directories recursively
+		FollowSymlinks bool             // walk into the symlinked directories (in the recursive mode)
+		SkipHidden     bool             // skip the hidden files and directories (the names starting with a dot)
+		MaxDepth       uint             // maximum depth of the recursive search (0 means no limit)
+		OnDiagnostic   func(Diagnostic) // called for the paths skipped because of the errors
+		Filters        []FileFilterFn   // filters for the files inside directories
+		DirFilters     []FileFilterFn   // filters for the nested directories (rejected ones are not walked into)
+	}
+
+	// Option represents a functional option for configuring the files search.
+	Option func(*options)
 )
 
-// FileFilterFn is a function type used to filter files.
-// It takes a fs.FileInfo object and returns a boolean indicating
-// whether the file should be included (true) or filtered out (false).
-type FileFilterFn = func(fs.FileInfo) bool
+// Apply applies the given options to the current options struct and returns the modified options.
+func (o options) Apply(opts ...Option) options {
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithRecursive enables (or disables) the recursive search in the directories.
+func WithRecursive(recursive bool) Option { return func(o *options) { o.Recursive = recursive } }
+
+// WithFollowSymlinks enables (or disables) walking into the symlinked directories in the recursive mode. The
+// symlink cycles are detected, and the files reachable by several paths are yielded only once.
+func WithFollowSymlinks(follow bool) Option { return func(o *options) { o.FollowSymlinks = follow } }
+
+// WithSkipHidden enables (or disables) skipping the hidden files and directories (the names starting with a dot,
+// e.g. ".git"), found inside the searched directories.
+func WithSkipHidden(skip bool) Option { return func(o *options) { o.SkipHidden = skip } }
+
+// WithMaxDepth limits the depth of the recursive search: 1 means only the files in the searched directories,
+// 2 - plus the files in their subdirectories, and so on. Zero means no limit.
+func WithMaxDepth(depth uint) Option { return func(o *options) { o.MaxDepth = depth } }
+
+// WithFilter adds the filters for the files found inside the directories. If any filter returns false,
+// the file is skipped.
+func WithFilter(fn ...FileFilterFn) Option {
+	return func(o *options) { o.Filters = append(o.Filters, fn...) }
+}
+
+// WithDirFilter adds the filters for the nested directories (in the recursive mode). If any filter returns false,
+// the directory is not walked into, so none of its files are yielded.
+func WithDirFilter(fn ...FileFilterFn) Option {
+	return func(o *options) { o.DirFilters = append(o.DirFilters, fn...) }
+}
 
 // FilterByExt creates a file filter function that filters files based on their extensions.
 // If caseSensitive is false, the extensions will be compared in a case-insensitive manner.
@@ -31,7 +93,7 @@ func FilterByExt(caseSensitive bool, exts ...string) FileFilterFn {
 	}
 
 	// return the filter function
-	return func(info fs.FileInfo) bool {
+	return func(info Entry) bool {
 		// skip directories
 		if info.IsDir() {
 			return false
@@ -53,52 +115,126 @@ func FilterByExt(caseSensitive bool, exts ...string) FileFilterFn {
 	}
 }
 
+// FilterBySize creates a file filter function that accepts only the files with the size in the range
+// [minSize, maxSize] (in bytes). Zero value means no limit. Directories are always accepted.
+func FilterBySize(minSize, maxSize uint64) FileFilterFn {
+	return func(e Entry) bool {
+		if e.IsDir() {
+			return true
+		}
+
+		var size = uint64(max(0, e.Size()))
+
+		return size >= minSize && (maxSize == 0 || size <= maxSize)
+	}
+}
+
+// FilterByModTime creates a file filter function that accepts only the files modified after the `after` time
+// and before the `before` one. Zero time means no limit. Directories are always accepted.
+func FilterByModTime(after, before time.Time) FileFilterFn {
+	return func(e Entry) bool {
+		if e.IsDir() {
+			return true
+		}
+
+		var modTime = e.ModTime()
+
+		return (after.IsZero() || modTime.After(after)) && (before.IsZero() || modTime.Before(before))
+	}
+}
+
+// FilterByContent creates a file filter function that accepts only the files with the content of the given MIME
+// types (e.g. validate.MimeTypePNG), regardless of the file names. The type is detected by the magic bytes at the
+// beginning of the file (see validate.ImageType), so the files that can't be read are rejected. Directories are
+// always accepted.
+func FilterByContent(mimeTypes ...string) FileFilterFn {
+	return func(e Entry) bool {
+		if e.IsDir() {
+			return true
+		}
+
+		f, err := os.Open(e.Path)
+		if err != nil {
+			return false
+		}
+
+		defer func() { _ = f.Close() }()
+
+		mimeType, err := validate.ImageType(f)
+		if err != nil || mimeType == "" {
+			return false
+		}
+
+		return slices.Contains(mimeTypes, mimeType)
+	}
+}
+
 // Files returns a sequence of absolute paths to files found in the specified paths (`where`).
 // If a path in `where` is a directory, it will be scanned for files.
-// If `recursive` is true, directories will be searched recursively.
+// If WithRecursive is set, directories will be searched recursively.
 //
-// The `filter` functions are applied only to files inside directories (not to the given file paths).
+// The filter functions (WithFilter) are applied only to files inside directories (not to the given file paths).
 // If any filter function returns false, the file is skipped. If any filesystem error occurs,
-// the file or directory is ignored.
+// the file or directory is ignored (and reported to the WithDiagnostics function, if set).
 //
 // Example usage:
 //
-//	for path := range Files(ctx, []string{"/path/to/dir", "/path/to/file.txt"}, true) {
+//	for path := range Files(ctx, []string{"/path/to/dir", "/path/to/file.txt"}, WithRecursive(true)) {
 //	    fmt.Println(path)
 //	}
 func Files(
 	ctx context.Context,
 	where []string,
-	recursive bool,
-	filter ...FileFilterFn,
+	opts ...Option,
 ) iter.Seq[string] {
-	var seq = make([]iter.Seq[string], 0, len(where))
+	var (
+		o   = options{}.Apply(opts...)
+		seq = make([]iter.Seq[string], 0, len(where))
+	)
 
 	for _, path := range where {
 		stat, err := os.Stat(path)
 		if err != nil {
+			o.diagnose(newDiagnostic(path, err))
+
 			continue // Ignore paths that cannot be accessed
 		}
 
 		if stat.IsDir() {
-			if recursive {
-				seq = append(seq, iterateFilesRecursive(ctx, path, filter...))
+			if o.Recursive {
+				seq = append(seq, iterateFilesRecursive(ctx, path, o))
 			} else {
-				seq = append(seq, iterateFiles(ctx, path, filter...))
+				seq = append(seq, iterateFiles(ctx, path, o))
 			}
 		} else {
-			seq = append(seq, singleFile(ctx, path))
+			seq = append(seq, singleFile(ctx, path, o))
 		}
 	}
 
 	// Combine all sequences into a single sequence
 	return func(yield func(string) bool) {
+		var seen map[string]struct{} // real paths of the yielded files (the same file may be reachable by the links)
+
+		if o.FollowSymlinks {
+			seen = make(map[string]struct{})
+		}
+
 		for _, s := range seq {
 			for path := range s {
 				if err := ctx.Err(); err != nil {
 					return // stop processing if the context is canceled
 				}
 
+				if seen != nil {
+					if realPath, err := filepath.EvalSymlinks(path); err == nil {
+						if _, dup := seen[realPath]; dup {
+							continue // skip the file, already yielded by another path
+						}
+
+						seen[realPath] = struct{}{}
+					}
+				}
+
 				if !yield(path) {
 					return // Stop yielding if the receiver stops accepting values
 				}
@@ -112,14 +248,19 @@ func Files(
 func singleFile(
 	ctx context.Context,
 	where string,
+	o options,
 ) iter.Seq[string] {
 	return func(yield func(string) bool) {
 		// convert to absolute path if needed
 		if !filepath.IsAbs(where) {
-			var absErr error
-			if where, absErr = filepath.Abs(where); absErr != nil {
+			abs, absErr := filepath.Abs(where)
+			if absErr != nil {
+				o.diagnose(Diagnostic{Path: where, Kind: DiagnosticUnresolvable, Err: absErr})
+
 				return // ignore files that can't be resolved to absolute paths
 			}
+
+			where = abs
 		}
 
 		if err := ctx.Err(); err != nil {
@@ -132,11 +273,11 @@ func singleFile(
 }
 
 // iterateFiles returns a sequence of absolute file paths inside the specified directory (non-recursively).
-// The function applies the provided filter functions to determine which files should be included.
+// The function applies the filter functions from the options to determine which files should be included.
 func iterateFiles( //nolint:gocognit
 	ctx context.Context,
 	where string,
-	filter ...FileFilterFn,
+	o options,
 ) iter.Seq[string] {
 	return func(yield func(string) bool) {
 		if err := ctx.Err(); err != nil {
@@ -145,26 +286,36 @@ func iterateFiles( //nolint:gocognit
 
 		f, openErr := os.Open(where)
 		if openErr != nil {
+			o.diagnose(newDiagnostic(where, openErr))
+
 			return // ignore directories that can't be opened
 		}
 
 		names, readErr := f.Readdirnames(-1) // read all file names in the directory
+
+		_ = f.Close() // close the directory after reading
+
 		if readErr != nil {
+			o.diagnose(newDiagnostic(where, readErr))
+
 			return
 		}
 
-		_ = f.Close() // close the directory after reading
-
 		slices.Sort(names)
 
-	loop:
 		for _, path := range names {
+			if o.SkipHidden && isHidden(path) {
+				continue
+			}
+
 			// construct the full file path
 			path = filepath.Join(where, path)
 
 			// Convert to absolute path if needed
 			if !filepath.IsAbs(path) {
 				if abs, err := filepath.Abs(path); err != nil {
+					o.diagnose(Diagnostic{Path: path, Kind: DiagnosticUnresolvable, Err: err})
+
 					return // ignore files that can't be resolved to absolute paths
 				} else {
 					path = abs
@@ -172,15 +323,18 @@ func iterateFiles( //nolint:gocognit
 			}
 
 			stat, statErr := os.Stat(path)
-			if statErr != nil || stat.IsDir() {
-				continue // skip directories and files with stat errors
+			if statErr != nil {
+				o.diagnose(newDiagnostic(path, statErr))
+
+				continue // skip files with stat errors
+			} else if stat.IsDir() {
+				continue // skip directories
 			}
 
-			// apply all filter functions
-			for _, fn := range filter {
-				if !fn(stat) {
-					continue loop // skip the file if it doesn't pass the filters
-				}
+			var entry = Entry{FileInfo: stat, Path: path, RelPath: stat.Name()}
+
+			if !applyFilters(entry, o.Filters) {
+				continue // skip the file if it doesn't pass the filters
 			}
 
 			if err := ctx.Err(); err != nil {
@@ -196,47 +350,30 @@ func iterateFiles( //nolint:gocognit
 }
 
 // iterateFilesRecursive returns a sequence of absolute file paths inside the specified directory recursively.
-// The function walks the directory tree and applies the provided filter functions.
+// The function walks the directory tree (see walker) and applies the filter functions from the options. Nested
+// directories, rejected by the directory filters, are skipped entirely.
 func iterateFilesRecursive(
 	ctx context.Context,
 	where string,
-	filter ...FileFilterFn,
+	o options,
 ) iter.Seq[string] {
 	return func(yield func(string) bool) {
-		_ = filepath.WalkDir(where, func(path string, d fs.DirEntry, walkErr error) error {
-			if walkErr != nil || d.IsDir() {
-				return nil // skip directories and walking errors
-			}
+		// convert to absolute path if needed
+		if !filepath.IsAbs(where) {
+			abs, absErr := filepath.Abs(where)
+			if absErr != nil {
+				o.diagnose(Diagnostic{Path: where, Kind: DiagnosticUnresolvable, Err: absErr})
 
-			info, err := d.Info()
-			if err != nil {
-				return nil // ignore files that can't be accessed
+				return // ignore directories that can't be resolved to absolute paths
 			}
 
-			// apply all filter functions
-			for _, fn := range filter {
-				if !fn(info) {
-					return nil // skip the file if it doesn't pass the filters
-				}
-			}
+			where = abs
+		}
 
-			// convert to absolute path if needed
-			if !filepath.IsAbs(path) {
-				if path, err = filepath.Abs(path); err != nil {
-					return nil // ignore files that can't be resolved to absolute paths
-				}
-			}
+		var w = walker{ctx: ctx, opts: o, yield: yield, visited: make(map[string]struct{})}
 
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return filepath.SkipAll // stop processing if the context is canceled
-			}
-
-			// yield the file path
-			if !yield(path) {
-				return filepath.SkipAll // stop traversal if the receiver stops accepting values
-			}
+		w.visit(where) // the searched directory itself is walked first
 
-			return nil
-		})
+		w.walk(where, "", 1)
 	}
 }
@@ -1,14 +1,15 @@
 package finder_test
 
 import (
-	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"gh.tarampamp.am/tinifier/v5/internal/finder"
+	"gh.tarampamp.am/tinifier/v5/internal/validate"
 )
 
 func TestFiles(t *testing.T) {
@@ -93,7 +94,7 @@ func TestFiles(t *testing.T) {
 				join("dir1", "file.png"),
 				join("dir1", "foobar"),
 			},
-			giveFilter: []finder.FileFilterFn{func(info fs.FileInfo) bool {
+			giveFilter: []finder.FileFilterFn{func(info finder.Entry) bool {
 				var (
 					isTxtFile = strings.HasSuffix(info.Name(), ".txt")
 					isJpgFile = strings.HasSuffix(info.Name(), ".jpg")
@@ -164,7 +165,10 @@ func TestFiles(t *testing.T) {
 				givePaths[i] = join(tmpDir, path)
 			}
 
-			res := finder.Files(t.Context(), givePaths, tc.giveRecursive, tc.giveFilter...)
+			res := finder.Files(t.Context(), givePaths,
+				finder.WithRecursive(tc.giveRecursive),
+				finder.WithFilter(tc.giveFilter...),
+			)
 
 			var slice = slices.Collect(res)
 
@@ -178,6 +182,122 @@ func TestFiles(t *testing.T) {
 	}
 }
 
+func TestFilterBySize(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveMin, giveMax uint64
+		giveSize         int64
+		giveDir          bool
+		want             bool
+	}{
+		"no limits":        {giveSize: 100, want: true},
+		"min, fits":        {giveMin: 100, giveSize: 100, want: true},
+		"min, too small":   {giveMin: 100, giveSize: 99},
+		"max, fits":        {giveMax: 100, giveSize: 100, want: true},
+		"max, too big":     {giveMax: 100, giveSize: 101},
+		"range, fits":      {giveMin: 10, giveMax: 20, giveSize: 15, want: true},
+		"range, too small": {giveMin: 10, giveMax: 20, giveSize: 5},
+		"directory":        {giveMin: 10, giveMax: 20, giveSize: 4096, giveDir: true, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var e = finder.Entry{FileInfo: sizedFileInfo{size: tc.giveSize, fakeFileInfo: fakeFileInfo{isDir: tc.giveDir}}}
+
+			assertEqual(t, tc.want, finder.FilterBySize(tc.giveMin, tc.giveMax)(e))
+		})
+	}
+}
+
+func TestFilterByModTime(t *testing.T) {
+	t.Parallel()
+
+	var (
+		now   = time.Now()
+		hour  = time.Hour
+		entry = func(modTime time.Time) finder.Entry {
+			return finder.Entry{FileInfo: sizedFileInfo{modTime: modTime}}
+		}
+	)
+
+	for name, tc := range map[string]struct {
+		giveAfter, giveBefore time.Time
+		giveModTime           time.Time
+		want                  bool
+	}{
+		"no limits":      {giveModTime: now, want: true},
+		"after, newer":   {giveAfter: now.Add(-hour), giveModTime: now, want: true},
+		"after, older":   {giveAfter: now.Add(-hour), giveModTime: now.Add(-2 * hour)},
+		"before, older":  {giveBefore: now.Add(-hour), giveModTime: now.Add(-2 * hour), want: true},
+		"before, newer":  {giveBefore: now.Add(-hour), giveModTime: now},
+		"range, fits":    {giveAfter: now.Add(-2 * hour), giveBefore: now, giveModTime: now.Add(-hour), want: true},
+		"range, too old": {giveAfter: now.Add(-2 * hour), giveBefore: now, giveModTime: now.Add(-3 * hour)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assertEqual(t, tc.want, finder.FilterByModTime(tc.giveAfter, tc.giveBefore)(entry(tc.giveModTime)))
+		})
+	}
+}
+
+func TestFilterByContent(t *testing.T) {
+	t.Parallel()
+
+	var tmpDir = t.TempDir()
+
+	for name, content := range map[string]string{
+		"png-with-no-ext":  "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR",
+		"jpeg.png":         "\xff\xd8\xff\xe0\x00\x10JFIF\x00",
+		"webp.bin":         "RIFF\x24\x00\x00\x00WEBPVP8 ",
+		"text.png":         "just a text",
+		"empty.jpg":        "",
+		"sub/nested-image": "\x89PNG\r\n\x1a\n",
+	} {
+		var path = filepath.Join(tmpDir, filepath.FromSlash(name))
+
+		assertNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assertNoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	var got = slices.Collect(finder.Files(t.Context(), []string{tmpDir},
+		finder.WithRecursive(true),
+		finder.WithFilter(finder.FilterByContent(validate.MimeTypePNG, validate.MimeTypeJPEG)),
+	))
+
+	for i, path := range got {
+		got[i] = filepath.ToSlash(strings.TrimPrefix(path, tmpDir+string(filepath.Separator)))
+	}
+
+	assertSlicesEqual(t, []string{"jpeg.png", "png-with-no-ext", "sub/nested-image"}, got)
+
+	// the files that can't be opened are rejected
+	assertEqual(t, false, finder.FilterByContent(validate.MimeTypePNG)(finder.Entry{
+		FileInfo: fakeFileInfo{name: "missing.png"},
+		Path:     filepath.Join(tmpDir, "missing.png"),
+	}))
+}
+
+// sizedFileInfo is the fs.FileInfo implementation with the size and modification time.
+type sizedFileInfo struct {
+	fakeFileInfo
+
+	size    int64
+	modTime time.Time
+}
+
+func (f sizedFileInfo) Size() int64        { return f.size }
+func (f sizedFileInfo) ModTime() time.Time { return f.modTime }
+
+func assertEqual[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+
+	if expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
 func assertSlicesEqual[T comparable](t *testing.T, expected, actual []T) {
 	t.Helper()
 
@@ -0,0 +1,149 @@
+package finder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// glob is a compiled glob pattern, split into the slash-separated segments.
+type glob struct {
+	segments []string
+	baseName bool // the pattern has no slashes, so it is matched against the base name only
+}
+
+// compileGlobs validates the glob patterns and compiles them.
+func compileGlobs(patterns ...string) ([]glob, error) {
+	var globs = make([]glob, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		var clean = strings.Trim(strings.TrimPrefix(pattern, "./"), "/")
+
+		if clean == "" {
+			return nil, fmt.Errorf("empty glob pattern %q", pattern)
+		}
+
+		var g = glob{segments: strings.Split(clean, "/"), baseName: !strings.Contains(clean, "/")}
+
+		for _, seg := range g.segments {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+			}
+		}
+
+		globs = append(globs, g)
+	}
+
+	return globs, nil
+}
+
+// Match reports whether the slash-separated relative path matches the pattern.
+func (g glob) Match(relPath string) bool {
+	if g.baseName {
+		return matchSegments(g.segments, []string{path.Base(relPath)})
+	}
+
+	return matchSegments(g.segments, strings.Split(relPath, "/"))
+}
+
+// MatchDir reports whether the whole directory content matches the pattern (e.g. "node_modules/**" for the
+// "node_modules" directory, or the pattern matching the directory itself).
+func (g glob) MatchDir(relPath string) bool {
+	if g.Match(relPath) {
+		return true
+	}
+
+	if n := len(g.segments); n > 1 && g.segments[n-1] == "**" {
+		return matchSegments(g.segments[:n-1], strings.Split(relPath, "/"))
+	}
+
+	return false
+}
+
+// matchSegments matches the path segments against the pattern segments. The "**" segment matches zero or more
+// path segments, other segments are matched using the [path.Match] rules ("*", "?" and character classes).
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for pattern = pattern[1:]; len(pattern) > 0 && pattern[0] == "**"; pattern = pattern[1:] {
+				// collapse the consecutive "**" segments
+			}
+
+			if len(pattern) == 0 {
+				return true // the trailing "**" matches everything
+			}
+
+			for i := range len(name) + 1 {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// FilterByGlob creates a file filter function that accepts only the files with the relative path (see
+// Entry.RelPath) matching any of the glob patterns. Patterns without slashes are matched against the file name,
+// and "**" matches any number of directories (e.g. "**/*.png" or "assets/**"). Directories are always accepted,
+// since their files may match.
+func FilterByGlob(patterns ...string) (FileFilterFn, error) {
+	globs, err := compileGlobs(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(e Entry) bool {
+		if e.IsDir() {
+			return true
+		}
+
+		for _, g := range globs {
+			if g.Match(e.RelPath) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// FilterOutByGlob creates a file filter function that rejects the files with the relative path (see
+// Entry.RelPath) matching any of the glob patterns (the syntax is the same as for FilterByGlob). Being used as a
+// directory filter (WithDirFilter), it rejects the directories whose whole content matches (e.g. "node_modules"
+// or "**/node_modules/**"), so they are not walked into.
+func FilterOutByGlob(patterns ...string) (FileFilterFn, error) {
+	globs, err := compileGlobs(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(e Entry) bool {
+		for _, g := range globs {
+			var match = g.Match
+
+			if e.IsDir() {
+				match = g.MatchDir
+			}
+
+			if match(e.RelPath) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
@@ -0,0 +1,156 @@
+package finder_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gh.tarampamp.am/tinifier/v5/internal/finder"
+)
+
+func TestFilterByGlob(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		givePatterns []string
+		giveRelPath  string
+		giveDir      bool
+		want         bool
+	}{
+		"base name":                   {givePatterns: []string{"*.png"}, giveRelPath: "a/b/c.png", want: true},
+		"base name, no match":         {givePatterns: []string{"*.png"}, giveRelPath: "a/b/c.jpg"},
+		"anchored":                    {givePatterns: []string{"a/*.png"}, giveRelPath: "a/c.png", want: true},
+		"anchored, nested":            {givePatterns: []string{"a/*.png"}, giveRelPath: "a/b/c.png"},
+		"double star prefix":          {givePatterns: []string{"**/*.min.png"}, giveRelPath: "x/y/z.min.png", want: true},
+		"double star prefix, root":    {givePatterns: []string{"**/*.min.png"}, giveRelPath: "z.min.png", want: true},
+		"double star suffix":          {givePatterns: []string{"assets/**"}, giveRelPath: "assets/i/j.png", want: true},
+		"double star in the middle":   {givePatterns: []string{"a/**/c.png"}, giveRelPath: "a/b1/b2/c.png", want: true},
+		"double star, zero dirs":      {givePatterns: []string{"a/**/c.png"}, giveRelPath: "a/c.png", want: true},
+		"leading dot slash":           {givePatterns: []string{"./a/*.png"}, giveRelPath: "a/c.png", want: true},
+		"character class":             {givePatterns: []string{"img[0-9].png"}, giveRelPath: "img7.png", want: true},
+		"any of the patterns":         {givePatterns: []string{"*.jpg", "*.png"}, giveRelPath: "c.png", want: true},
+		"directories are always kept": {givePatterns: []string{"*.png"}, giveRelPath: "dir", giveDir: true, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fn, err := finder.FilterByGlob(tc.givePatterns...)
+			assertNoError(t, err)
+
+			if got := fn(newEntry(tc.giveRelPath, tc.giveDir)); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		t.Parallel()
+
+		for _, pattern := range []string{"a/[", "", "/"} {
+			if _, err := finder.FilterByGlob(pattern); err == nil {
+				t.Errorf("expected an error for the pattern %q", pattern)
+			}
+		}
+	})
+}
+
+func TestFilterOutByGlob(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		givePatterns []string
+		giveRelPath  string
+		giveDir      bool
+		want         bool
+	}{
+		"file":                         {givePatterns: []string{"**/*.min.png"}, giveRelPath: "a/b.min.png"},
+		"file, no match":               {givePatterns: []string{"**/*.min.png"}, giveRelPath: "a/b.png", want: true},
+		"dir by name":                  {givePatterns: []string{"node_modules"}, giveRelPath: "a/node_modules", giveDir: true},
+		"dir with double star":         {givePatterns: []string{"node_modules/**"}, giveRelPath: "node_modules", giveDir: true},
+		"nested dir with double stars": {givePatterns: []string{"**/cache/**"}, giveRelPath: "a/b/cache", giveDir: true},
+		"dir, partial match":           {givePatterns: []string{"a/*.png"}, giveRelPath: "a", giveDir: true, want: true},
+		"dir, no match":                {givePatterns: []string{"node_modules/**"}, giveRelPath: "src", giveDir: true, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fn, err := finder.FilterOutByGlob(tc.givePatterns...)
+			assertNoError(t, err)
+
+			if got := fn(newEntry(tc.giveRelPath, tc.giveDir)); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFiles_Globs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		tmpDir = t.TempDir()
+		join   = filepath.Join
+	)
+
+	for _, file := range []string{
+		"a.png",
+		"a.min.png",
+		join("assets", "b.png"),
+		join("assets", "b.min.png"),
+		join("node_modules", "pkg", "c.png"),
+		join("src", "node_modules", "d.png"),
+	} {
+		assertNoError(t, os.MkdirAll(join(tmpDir, filepath.Dir(file)), 0o755))
+		assertNoError(t, os.WriteFile(join(tmpDir, file), nil, 0o600))
+	}
+
+	include, err := finder.FilterByGlob("*.png")
+	assertNoError(t, err)
+
+	exclude, err := finder.FilterOutByGlob("**/*.min.png", "node_modules/**", "**/node_modules")
+	assertNoError(t, err)
+
+	var (
+		visited []string // files checked by the filters (the files in the pruned directories are never checked)
+		visit   = func(e finder.Entry) bool { visited = append(visited, e.RelPath); return true }
+	)
+
+	var got = slices.Collect(finder.Files(t.Context(), []string{tmpDir},
+		finder.WithRecursive(true),
+		finder.WithFilter(visit, include, exclude),
+		finder.WithDirFilter(exclude),
+	))
+
+	for i, path := range got {
+		got[i] = strings.TrimPrefix(path, tmpDir+string(filepath.Separator))
+	}
+
+	assertSlicesEqual(t, []string{"a.png", join("assets", "b.png")}, got)
+	assertSlicesEqual(t, []string{"a.min.png", "a.png", "assets/b.min.png", "assets/b.png"}, visited)
+}
+
+// fakeFileInfo is the fs.FileInfo implementation for the filters testing.
+type fakeFileInfo struct {
+	name  string
+	isDir bool
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() fs.FileMode  { return 0 }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return f.isDir }
+func (f fakeFileInfo) Sys() any           { return nil }
+
+// newEntry creates an entry with the given relative path.
+func newEntry(relPath string, isDir bool) finder.Entry {
+	return finder.Entry{
+		FileInfo: fakeFileInfo{name: filepath.Base(relPath), isDir: isDir},
+		Path:     "/" + relPath,
+		RelPath:  relPath,
+	}
+}
@@ -0,0 +1,181 @@
+package finder
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is the name of the file with the patterns of the files (and directories) to skip. It has the same
+// syntax and semantics as the ".gitignore" file.
+const IgnoreFileName = ".tinifierignore"
+
+// ignoreRule is a single pattern from the ignore file.
+type ignoreRule struct {
+	glob         // pattern relative to the directory of the ignore file
+	negate  bool // the "!" prefix - re-include the matching files
+	dirOnly bool // the trailing "/" - the pattern matches directories only
+}
+
+// parseIgnoreRules reads the rules from the ignore file content, using the ".gitignore" syntax:
+//   - blank lines and lines starting with "#" are skipped (use "\#" for the patterns starting with "#")
+//   - the "!" prefix negates the pattern (use "\!" for the patterns starting with "!")
+//   - the trailing "/" makes the pattern match only directories
+//   - patterns with a slash at the beginning or in the middle are relative to the ignore file directory,
+//     otherwise they match at any level below it
+//   - "**" matches any number of directories, the trailing "/**" matches everything inside
+//
+// Invalid patterns are skipped silently (the same as git does).
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var (
+		rules   []ignoreRule
+		scanner = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		var line = strings.TrimSuffix(scanner.Text(), "\r")
+
+		// trailing spaces are ignored, unless they are escaped with a backslash
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+
+		switch {
+		case strings.HasPrefix(line, "!"):
+			rule.negate, line = true, line[1:]
+		case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		var anchored = strings.Contains(line, "/")
+
+		line = strings.TrimPrefix(line, "/")
+		rule.segments = strings.Split(line, "/")
+
+		if !anchored {
+			rule.segments = append([]string{"**"}, rule.segments...) // match at any level
+		}
+
+		if n := len(rule.segments); n > 1 && rule.segments[n-1] == "**" {
+			rule.segments = append(rule.segments, "*") // "foo/**" matches the content, but not the "foo" itself
+		}
+
+		if validSegments(rule.segments) {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// validSegments reports whether all the pattern segments are valid.
+func validSegments(segments []string) bool {
+	for _, seg := range segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ignoreFiles loads and caches the rules from the ignore files in the directories. It is safe for concurrent use.
+type ignoreFiles struct {
+	names []string // names of the ignore files (the rules from the latter files have priority)
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // the key is the absolute directory path
+}
+
+// dirRules returns the rules from the ignore files in the directory (loaded once).
+func (f *ignoreFiles) dirRules(dir string) []ignoreRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rules, ok := f.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+
+	for _, name := range f.names {
+		if file, err := os.Open(filepath.Join(dir, name)); err == nil {
+			if fileRules, parseErr := parseIgnoreRules(file); parseErr == nil {
+				rules = append(rules, fileRules...)
+			}
+
+			_ = file.Close()
+		}
+	}
+
+	f.rules[dir] = rules
+
+	return rules
+}
+
+// Ignored reports whether the entry is ignored by the rules from the ignore files in the searched directory and
+// all the nested directories down to the entry parent. The last matching rule wins, so the rules from the deeper
+// ignore files have priority.
+func (f *ignoreFiles) Ignored(e Entry) bool {
+	var (
+		relPath = path.Clean(e.RelPath)
+		root    = strings.TrimSuffix(e.Path, filepath.FromSlash(relPath))
+		parts   = strings.Split(relPath, "/")
+		ignored bool
+	)
+
+	// the entry path relative to the directory of the ignore file is matched
+	for i := range parts {
+		var dir = filepath.Join(root, filepath.FromSlash(path.Join(parts[:i]...)))
+
+		for _, rule := range f.dirRules(dir) {
+			if rule.dirOnly && !e.IsDir() {
+				continue
+			}
+
+			if matchSegments(rule.segments, parts[i:]) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// FilterByIgnoreFiles creates a file filter function that rejects the files (and directories, being used with
+// WithDirFilter) ignored by the rules from the ignore files with the given names (e.g. IgnoreFileName or
+// ".gitignore"), found in the searched directory and the nested ones. The ignore files use the ".gitignore" syntax
+// and semantics (negation, anchored and directory-only patterns). The files inside the ignored directory cannot be
+// re-included, since the directory is not walked into.
+func FilterByIgnoreFiles(names ...string) FileFilterFn {
+	var f = ignoreFiles{names: names, rules: make(map[string][]ignoreRule)}
+
+	return func(e Entry) bool { return !f.Ignored(e) }
+}
+
+// WithIgnoreFiles makes the search honor the ignore files with the given names (see FilterByIgnoreFiles).
+func WithIgnoreFiles(names ...string) Option {
+	return func(o *options) {
+		var fn = FilterByIgnoreFiles(names...)
+
+		o.Filters, o.DirFilters = append(o.Filters, fn), append(o.DirFilters, fn)
+	}
+}
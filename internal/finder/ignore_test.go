@@ -0,0 +1,135 @@
+package finder_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/internal/finder"
+)
+
+func TestWithIgnoreFiles(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveFiles map[string]string // path => content
+		giveNames []string
+		want      []string
+	}{
+		"no ignore files": {
+			giveFiles: map[string]string{"a.png": "", "b/c.png": ""},
+			want:      []string{"a.png", "b/c.png"},
+		},
+		"common": {
+			giveFiles: map[string]string{
+				".tinifierignore":  "# comment\n\n*.min.png\nvendor/\n!keep.min.png\n",
+				"a.png":            "",
+				"a.min.png":        "",
+				"keep.min.png":     "",
+				"sub/b.min.png":    "",
+				"vendor/c.png":     "",
+				"sub/vendor/d.png": "",
+			},
+			want: []string{"a.png", "keep.min.png"},
+		},
+		"anchored": {
+			giveFiles: map[string]string{
+				".tinifierignore":  "/top.png\nsub/deep/*.png\n",
+				"top.png":          "",
+				"sub/top.png":      "",
+				"sub/deep/a.png":   "",
+				"x/sub/deep/b.png": "",
+			},
+			want: []string{"sub/top.png", "x/sub/deep/b.png"},
+		},
+		"directory only": {
+			giveFiles: map[string]string{
+				".tinifierignore": "build/\n",
+				"build/a.png":     "",
+				"x/build":         "",
+			},
+			want: []string{"x/build"},
+		},
+		"double stars": {
+			giveFiles: map[string]string{
+				".tinifierignore": "assets/**\n!assets/keep.png\n**/tmp/*.png\n",
+				"assets/a.png":    "",
+				"assets/keep.png": "",
+				"a/b/tmp/c.png":   "",
+				"tmp/d.png":       "",
+				"e.png":           "",
+			},
+			want: []string{"assets/keep.png", "e.png"},
+		},
+		"nested ignore file has priority": {
+			giveFiles: map[string]string{
+				".tinifierignore":     "*.jpg\n",
+				"a.jpg":               "",
+				"sub/.tinifierignore": "!ok.jpg\n",
+				"sub/ok.jpg":          "",
+				"sub/no.jpg":          "",
+			},
+			want: []string{"sub/ok.jpg"},
+		},
+		"escaped": {
+			giveFiles: map[string]string{
+				".tinifierignore": "\\#hash.png\n\\!bang.png\n",
+				"#hash.png":       "",
+				"!bang.png":       "",
+				"a.png":           "",
+			},
+			want: []string{"a.png"},
+		},
+		"several ignore files": {
+			giveFiles: map[string]string{
+				".gitignore":      "*.png\n",
+				".tinifierignore": "!a.png\n",
+				"a.png":           "",
+				"b.png":           "",
+			},
+			giveNames: []string{".gitignore", finder.IgnoreFileName},
+			want:      []string{"a.png"},
+		},
+		"other ignore files are not used": {
+			giveFiles: map[string]string{
+				".gitignore": "*.png\n",
+				"a.png":      "",
+			},
+			want: []string{"a.png"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var tmpDir = t.TempDir()
+
+			for path, content := range tc.giveFiles {
+				path = filepath.Join(tmpDir, filepath.FromSlash(path))
+
+				assertNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				assertNoError(t, os.WriteFile(path, []byte(content), 0o600))
+			}
+
+			var names = tc.giveNames
+			if names == nil {
+				names = []string{finder.IgnoreFileName}
+			}
+
+			var got = slices.Collect(finder.Files(t.Context(), []string{tmpDir},
+				finder.WithRecursive(true),
+				finder.WithFilter(func(e finder.Entry) bool { return !strings.HasPrefix(e.Name(), ".") }),
+				finder.WithIgnoreFiles(names...),
+			))
+
+			for i, path := range got {
+				got[i] = filepath.ToSlash(strings.TrimPrefix(path, tmpDir+string(filepath.Separator)))
+			}
+
+			slices.Sort(got)
+
+			assertSlicesEqual(t, tc.want, got)
+		})
+	}
+}
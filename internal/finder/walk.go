@@ -0,0 +1,123 @@
+package finder
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// walker walks the directory tree recursively. Unlike the [filepath.WalkDir], it follows the symbolic links to
+// directories (if enabled, with the cycles detection), skips the hidden files and limits the walking depth.
+type walker struct {
+	ctx     context.Context
+	opts    options
+	yield   func(string) bool
+	visited map[string]struct{} // real paths of the walked directories (used only when following the symlinks)
+}
+
+// walk walks the directory with the given absolute path (relDir is the slash-separated path relative to the
+// searched directory, and depth is the depth of the directory entries). Entries are walked in lexical order.
+// It returns false if the walking should be stopped.
+func (w *walker) walk(dir, relDir string, depth uint) bool { //nolint:gocognit
+	entries, readErr := os.ReadDir(dir)
+	if readErr != nil {
+		w.opts.diagnose(newDiagnostic(dir, readErr)) // the entries read before the error are still walked
+	}
+
+	for _, d := range entries {
+		if w.ctx.Err() != nil {
+			return false // stop processing if the context is canceled
+		}
+
+		if w.opts.SkipHidden && isHidden(d.Name()) {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			w.opts.diagnose(newDiagnostic(filepath.Join(dir, d.Name()), err))
+
+			continue // ignore files that can't be accessed
+		}
+
+		var entry = Entry{FileInfo: info, Path: filepath.Join(dir, d.Name()), RelPath: path.Join(relDir, d.Name())}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if entry.FileInfo, err = os.Stat(entry.Path); err != nil {
+				w.opts.diagnose(newDiagnostic(entry.Path, err))
+
+				continue // skip broken links
+			}
+
+			if entry.IsDir() && !w.opts.FollowSymlinks {
+				continue
+			}
+		}
+
+		if entry.IsDir() {
+			if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+				continue // the directory content is too deep
+			}
+
+			if !applyFilters(entry, w.opts.DirFilters) || !w.visit(entry.Path) {
+				continue // do not walk into the rejected (or already walked) directory
+			}
+
+			if !w.walk(entry.Path, entry.RelPath, depth+1) {
+				return false
+			}
+
+			continue
+		}
+
+		if !applyFilters(entry, w.opts.Filters) {
+			continue // skip the file if it doesn't pass the filters
+		}
+
+		if !w.yield(entry.Path) {
+			return false // stop traversal if the receiver stops accepting values
+		}
+	}
+
+	return true
+}
+
+// visit marks the directory as walked, and reports whether it was not walked before. The directories are
+// tracked only when following the symlinks, since otherwise the same directory can't be reached twice.
+func (w *walker) visit(dir string) bool {
+	if !w.opts.FollowSymlinks {
+		return true
+	}
+
+	realPath, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		w.opts.diagnose(Diagnostic{Path: dir, Kind: DiagnosticUnresolvable, Err: err})
+
+		return false
+	}
+
+	if _, ok := w.visited[realPath]; ok {
+		return false // the symlink cycle (or the directory, linked twice)
+	}
+
+	w.visited[realPath] = struct{}{}
+
+	return true
+}
+
+// applyFilters reports whether the entry passes all the filters.
+func applyFilters(e Entry, filters []FileFilterFn) bool {
+	for _, fn := range filters {
+		if !fn(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHidden reports whether the file (or directory) with the given name is hidden (the name starts with a dot).
+func isHidden(name string) bool { return strings.HasPrefix(name, ".") }
@@ -0,0 +1,103 @@
+package finder_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/internal/finder"
+)
+
+func TestFiles_Walking(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		giveFiles    []string
+		giveSymlinks map[string]string // link => target (relative to the link directory)
+		giveOptions  []finder.Option
+		want         []string
+	}{
+		"hidden files are included by default": {
+			giveFiles: []string{"a.png", ".b.png", ".git/c.png"},
+			want:      []string{".b.png", ".git/c.png", "a.png"},
+		},
+		"skip hidden": {
+			giveFiles:   []string{"a.png", ".b.png", ".git/c.png", "d/.e.png", "d/f.png"},
+			giveOptions: []finder.Option{finder.WithSkipHidden(true)},
+			want:        []string{"a.png", "d/f.png"},
+		},
+		"max depth": {
+			giveFiles:   []string{"a.png", "b/c.png", "b/d/e.png", "b/d/f/g.png"},
+			giveOptions: []finder.Option{finder.WithMaxDepth(2)},
+			want:        []string{"a.png", "b/c.png"},
+		},
+		"max depth, only the searched directory": {
+			giveFiles:   []string{"a.png", "b/c.png"},
+			giveOptions: []finder.Option{finder.WithMaxDepth(1)},
+			want:        []string{"a.png"},
+		},
+		"symlinked directories are skipped by default": {
+			giveFiles:    []string{"a.png", "real/b.png"},
+			giveSymlinks: map[string]string{"link": "real", "c.png": "a.png"},
+			want:         []string{"a.png", "c.png", "real/b.png"},
+		},
+		"follow symlinks": {
+			giveFiles:    []string{"a.png", "real/b.png", "../outside/d.png"},
+			giveSymlinks: map[string]string{"link": "../outside"},
+			giveOptions:  []finder.Option{finder.WithFollowSymlinks(true)},
+			want:         []string{"a.png", "link/d.png", "real/b.png"},
+		},
+		"follow symlinks, duplicates": {
+			giveFiles:    []string{"a.png", "real/b.png"},
+			giveSymlinks: map[string]string{"link": "real", "real/c.png": "../a.png"},
+			giveOptions:  []finder.Option{finder.WithFollowSymlinks(true)},
+			want:         []string{"a.png", "link/b.png"}, // "link" goes first in the lexical order
+		},
+		"follow symlinks, cycle": {
+			giveFiles:    []string{"a/b.png"},
+			giveSymlinks: map[string]string{"a/loop": "..", "a/self": "."},
+			giveOptions:  []finder.Option{finder.WithFollowSymlinks(true)},
+			want:         []string{"a/b.png"},
+		},
+		"broken symlink": {
+			giveFiles:    []string{"a.png"},
+			giveSymlinks: map[string]string{"broken.png": "missing.png"},
+			giveOptions:  []finder.Option{finder.WithFollowSymlinks(true)},
+			want:         []string{"a.png"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var root = filepath.Join(t.TempDir(), "root")
+
+			for _, file := range append([]string{"."}, tc.giveFiles...) {
+				var path = filepath.Join(root, filepath.FromSlash(file))
+
+				assertNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+
+				if file != "." {
+					assertNoError(t, os.WriteFile(path, nil, 0o600))
+				}
+			}
+
+			for link, target := range tc.giveSymlinks {
+				if err := os.Symlink(filepath.FromSlash(target), filepath.Join(root, filepath.FromSlash(link))); err != nil {
+					t.Skipf("symlinks are not supported: %s", err)
+				}
+			}
+
+			var got = slices.Collect(finder.Files(t.Context(), []string{root},
+				append([]finder.Option{finder.WithRecursive(true)}, tc.giveOptions...)...,
+			))
+
+			for i, path := range got {
+				got[i] = filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+			}
+
+			assertSlicesEqual(t, tc.want, got)
+		})
+	}
+}
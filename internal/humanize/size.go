@@ -1,6 +1,12 @@
 package humanize
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
 
 // Bytes returns a human-readable representation of a size in bytes.
 func Bytes[T integer](bytes T) string {
@@ -39,3 +45,46 @@ func Bytes[T integer](bytes T) string {
 func BytesDiff[A, B integer](a A, b B) string {
 	return Bytes(int64(a) - int64(b))
 }
+
+// ParseBytes parses a human-readable size (e.g. "512", "100KB", "1.5 MB" or "2mib") into the number of bytes.
+// The units are case-insensitive and use the binary multiples (1 KB = 1024 bytes), the same as Bytes does.
+func ParseBytes(s string) (uint64, error) {
+	var (
+		trimmed = strings.TrimSpace(s)
+		idx     = strings.IndexFunc(trimmed, func(r rune) bool { return unicode.IsLetter(r) })
+		number  = trimmed
+		unit    string
+	)
+
+	if idx >= 0 {
+		number, unit = strings.TrimSpace(trimmed[:idx]), strings.ToLower(trimmed[idx:])
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	var multiplier float64
+
+	switch unit {
+	case "", "b":
+		multiplier = 1
+	case "k", "kb", "kib":
+		multiplier = 1 << 10
+	case "m", "mb", "mib":
+		multiplier = 1 << 20
+	case "g", "gb", "gib":
+		multiplier = 1 << 30
+	case "t", "tb", "tib":
+		multiplier = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid size unit %q", unit)
+	}
+
+	if result := value * multiplier; result < math.MaxUint64 {
+		return uint64(result), nil
+	}
+
+	return 0, fmt.Errorf("size %q is too large", s)
+}
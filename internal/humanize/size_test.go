@@ -42,6 +42,34 @@ func TestBytesDiff(t *testing.T) {
 	assertEqual(t, "-1.00 TB", humanize.BytesDiff(-1099511627776, 5368709120))
 }
 
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+
+	for give, want := range map[string]uint64{
+		"0":       0,
+		"512":     512,
+		"512b":    512,
+		" 100KB ": 100 * 1024,
+		"1.5 MB":  1536 * 1024,
+		"2mib":    2 * 1024 * 1024,
+		"1G":      1 << 30,
+		"1TB":     1 << 40,
+	} {
+		got, err := humanize.ParseBytes(give)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", give, err)
+		}
+
+		assertEqual(t, want, got)
+	}
+
+	for _, give := range []string{"", "KB", "-1", "1 XB", "1.2.3", "abc", "1e30TB"} {
+		if _, err := humanize.ParseBytes(give); err == nil {
+			t.Errorf("expected an error for %q", give)
+		}
+	}
+}
+
 // assertEqual checks if two values of a comparable type are equal.
 func assertEqual[T comparable](t *testing.T, want, got T) {
 	t.Helper()
@@ -4,17 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"time"
 )
 
 type (
 	options struct {
-		DelayBetweenAttempts time.Duration // duration to wait between retry attempts
-		StopOnError          []error       // list of errors that should stop the retry loop immediately
+		DelayBetweenAttempts time.Duration    // duration to wait between retry attempts (the base one for the backoff)
+		MaxDelay             time.Duration    // maximal delay between attempts, including Retry-After (0 = no limit)
+		Exponential          bool             // double the delay after every attempt
+		Jitter               bool             // use a random delay in [0, delay) instead of the exact one
+		StopOnError          []error          // list of errors that should stop the retry loop immediately
+		Retryable            func(error) bool // reports whether the error is retryable (nil means all the errors are)
 	}
 
 	// Option represents a functional option for configuring retry behavior.
 	Option func(*options)
+
+	// RetryAfterError is implemented by errors carrying the delay suggested by the server (e.g. from the HTTP
+	// "Retry-After" header). Such a delay is used instead of the calculated one, when it is longer (but it's still
+	// limited by the max delay).
+	RetryAfterError interface {
+		error
+		RetryAfter() time.Duration
+	}
 )
 
 // Apply applies the given options to the current options struct and returns the modified options.
@@ -31,6 +45,25 @@ func WithDelayBetweenAttempts(d time.Duration) Option {
 	return func(o *options) { o.DelayBetweenAttempts = d }
 }
 
+// WithExponentialBackoff enables the exponential backoff: the delay starts from the base duration and doubles after
+// every failed attempt, but never exceeds the max duration (zero max means no limit).
+func WithExponentialBackoff(base, maxDelay time.Duration) Option {
+	return func(o *options) { o.DelayBetweenAttempts, o.MaxDelay, o.Exponential = base, maxDelay, true }
+}
+
+// WithMaxDelay limits the delay between attempts, including the one suggested by the server (zero means no limit).
+func WithMaxDelay(d time.Duration) Option {
+	return func(o *options) { o.MaxDelay = d }
+}
+
+// WithJitter enables the "full jitter": the delay between attempts is a random duration in the range
+// [0, calculated delay), which helps to spread the retries of concurrent workers over time.
+func WithJitter() Option { return func(o *options) { o.Jitter = true } }
+
+// WithRetryable sets the function, which reports whether the error is retryable. Non-retryable errors stop the
+// retry loop immediately (the same as the errors set using WithStopOnError).
+func WithRetryable(fn func(error) bool) Option { return func(o *options) { o.Retryable = fn } }
+
 // WithStopOnError sets the list of errors that should immediately stop the retry loop when encountered.
 func WithStopOnError(e ...error) Option {
 	return func(o *options) { o.StopOnError = append(o.StopOnError, e...) }
@@ -39,6 +72,51 @@ func WithStopOnError(e ...error) Option {
 // ErrRetryAttemptsExceeded is returned when the function exceeds the allowed number of retry attempts.
 var ErrRetryAttemptsExceeded = errors.New("retry attempts exceeded")
 
+// stop reports whether the error should stop the retry loop immediately.
+func (o options) stop(err error) bool {
+	for _, errToStop := range o.StopOnError {
+		if errors.Is(err, errToStop) {
+			return true
+		}
+	}
+
+	return o.Retryable != nil && !o.Retryable(err)
+}
+
+// delay returns the duration to wait after the failed attempt (the attempt number starts from 1).
+func (o options) delay(attempt uint, err error) time.Duration {
+	var d = o.DelayBetweenAttempts
+
+	if o.Exponential && d > 0 {
+		for i := uint(1); i < attempt && d < math.MaxInt64/2; i++ { // the limit prevents the overflow
+			if o.MaxDelay > 0 && d >= o.MaxDelay {
+				break
+			}
+
+			d *= 2
+		}
+
+		if o.MaxDelay > 0 {
+			d = min(d, o.MaxDelay)
+		}
+	}
+
+	if o.Jitter && d > 0 {
+		d = rand.N(d)
+	}
+
+	// the delay suggested by the server has priority, if it is longer
+	if ra, ok := errors.AsType[RetryAfterError](err); ok {
+		d = max(d, ra.RetryAfter())
+	}
+
+	if o.MaxDelay > 0 { // the max delay is the hard limit, even for the server suggestion
+		d = min(d, o.MaxDelay)
+	}
+
+	return d
+}
+
 // Try executes the given function `fn` until it succeeds, the maximum number of attempts is reached,
 // or a stop condition is met.
 //
@@ -46,7 +124,11 @@ var ErrRetryAttemptsExceeded = errors.New("retry attempts exceeded")
 // If the maximum number of attempts is reached, the function returns ErrRetryAttemptsExceeded, wrapped
 // with the last encountered error (you need to use [errors.Is] to check for this error).
 // If the context is canceled, the function returns the context error.
-// If WithDelayBetweenAttempts is set, the function waits for the specified duration before retrying.
+// If `fn` returns an error that is not retryable (see WithRetryable), the retry loop stops immediately too.
+// If WithDelayBetweenAttempts is set, the function waits for the specified duration before retrying. With
+// WithExponentialBackoff the delay grows after every attempt, and WithJitter randomizes it. If the error implements
+// RetryAfterError, the delay suggested by the error is used when it is longer than the calculated one. In any
+// case, the delay never exceeds the max one (see WithMaxDelay and WithExponentialBackoff).
 //
 // It returns nil if `fn` succeeds within the allowed attempts, or an error if the function ultimately fails,
 // either due to reaching the maximum attempts or encountering a stop condition.
@@ -62,11 +144,11 @@ func Try(
 		timer   *time.Timer
 	)
 
-	// initialize the timer if a delay between attempts is specified
-	if o.DelayBetweenAttempts > time.Duration(0) {
-		timer = time.NewTimer(o.DelayBetweenAttempts)
-		defer timer.Stop()
-	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
 
 	for {
 		// check if the context was canceled before attempting the function
@@ -83,9 +165,18 @@ func Try(
 				return fmt.Errorf("%w: %w", ErrRetryAttemptsExceeded, err)
 			}
 
-			// if a delay is specified, wait for the next attempt unless the context is canceled
-			if timer != nil {
-				timer.Reset(o.DelayBetweenAttempts)
+			// check if the error matches any in StopOnError or is not retryable; if so, exit immediately
+			if o.stop(err) {
+				return err
+			}
+
+			// if a delay is needed, wait for the next attempt unless the context is canceled
+			if d := o.delay(attempt, err); d > 0 {
+				if timer == nil {
+					timer = time.NewTimer(d)
+				} else {
+					timer.Reset(d)
+				}
 
 				select {
 				case <-ctx.Done():
@@ -94,13 +185,6 @@ func Try(
 				}
 			}
 
-			// check if the error matches any in StopOnError; if so, exit immediately
-			for _, errToStop := range o.StopOnError {
-				if errors.Is(err, errToStop) {
-					return err
-				}
-			}
-
 			continue
 		}
 
@@ -85,6 +85,120 @@ func TestTry(t *testing.T) {
 		assertErrorIs(t, err, errToStop)
 	})
 
+	t.Run("exponential backoff", func(t *testing.T) {
+		t.Parallel()
+
+		var now = time.Now()
+
+		const (
+			base      = 5 * time.Millisecond
+			maxDelay  = 12 * time.Millisecond
+			finalTime = base + 2*base + maxDelay // 5ms + 10ms + 12ms (instead of 20ms)
+		)
+
+		var err = retry.Try(
+			t.Context(),
+			4,
+			func(_ context.Context, _ uint) error { return errors.New("error") },
+			retry.WithExponentialBackoff(base, maxDelay),
+		)
+
+		assertErrorIs(t, err, retry.ErrRetryAttemptsExceeded)
+		assertEqual(t, true, time.Since(now).Round(time.Millisecond) >= finalTime)
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			now     = time.Now()
+			counter uint
+		)
+
+		var err = retry.Try(
+			t.Context(),
+			3,
+			func(_ context.Context, _ uint) error { counter++; return errors.New("error") },
+			retry.WithDelayBetweenAttempts(10*time.Millisecond),
+			retry.WithJitter(),
+		)
+
+		assertEqual(t, 3, counter)
+		assertErrorIs(t, err, retry.ErrRetryAttemptsExceeded)
+		assertEqual(t, true, time.Since(now) < time.Second)
+	})
+
+	t.Run("not retryable error", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			counter      uint
+			fatalErr     = errors.New("fatal")
+			retryableErr = errors.New("retryable")
+		)
+
+		var err = retry.Try(
+			t.Context(),
+			5,
+			func(_ context.Context, attempt uint) error {
+				counter++
+
+				if attempt < 3 {
+					return retryableErr
+				}
+
+				return fatalErr
+			},
+			retry.WithRetryable(func(err error) bool { return !errors.Is(err, fatalErr) }),
+		)
+
+		assertEqual(t, 3, counter)
+		assertErrorIs(t, err, fatalErr)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		t.Parallel()
+
+		var now = time.Now()
+
+		const delay = 20 * time.Millisecond
+
+		var err = retry.Try(
+			t.Context(),
+			2,
+			func(_ context.Context, _ uint) error { return retryAfterError(delay) },
+			retry.WithDelayBetweenAttempts(time.Millisecond), // shorter than suggested, so ignored
+		)
+
+		assertErrorIs(t, err, retry.ErrRetryAttemptsExceeded)
+		assertEqual(t, true, time.Since(now).Round(time.Millisecond) >= delay)
+	})
+
+	t.Run("retry after is limited by the max delay", func(t *testing.T) {
+		t.Parallel()
+
+		for name, opt := range map[string]retry.Option{
+			"max delay":           retry.WithMaxDelay(5 * time.Millisecond),
+			"exponential backoff": retry.WithExponentialBackoff(time.Millisecond, 5*time.Millisecond),
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				var now = time.Now()
+
+				var err = retry.Try(
+					t.Context(),
+					2,
+					func(_ context.Context, _ uint) error { return retryAfterError(time.Hour) },
+					opt,
+				)
+
+				assertErrorIs(t, err, retry.ErrRetryAttemptsExceeded)
+				assertEqual(t, true, time.Since(now) < time.Second) // not an hour
+			})
+		}
+	})
+
 	t.Run("on canceled context", func(t *testing.T) {
 		t.Parallel()
 
@@ -130,6 +244,11 @@ func TestTry(t *testing.T) {
 	})
 }
 
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string             { return "retry later" }
+func (e retryAfterError) RetryAfter() time.Duration { return time.Duration(e) }
+
 func assertEqual[T comparable](t *testing.T, expected, actual T) {
 	t.Helper()
 
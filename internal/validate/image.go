@@ -1,12 +1,22 @@
 package validate
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"io"
 	"net/http"
 	"strings"
 )
 
+// MIME types of the images, supported by the TinyPNG API (and detected by ImageType).
+const (
+	MimeTypePNG  = "image/png"
+	MimeTypeJPEG = "image/jpeg"
+	MimeTypeWebP = "image/webp"
+	MimeTypeAVIF = "image/avif"
+)
+
 // IsImage checks for passed content is image or not.
 // Do not forget to reset the source (offset will be changed after this function calling).
 func IsImage(src io.Reader) (bool, error) {
@@ -24,3 +34,60 @@ func IsImage(src io.Reader) (bool, error) {
 
 	return strings.HasPrefix(http.DetectContentType(buf), "image/"), nil
 }
+
+// ImageType detects the type of the image, supported by the TinyPNG API (PNG, JPEG, WebP or AVIF), by the magic
+// bytes at the beginning of the content. It returns the MIME type, or an empty string if the content is not such
+// an image.
+// Do not forget to reset the source (offset will be changed after this function calling), if it is not a seeker.
+func ImageType(src io.Reader) (string, error) {
+	buf := make([]byte, 64) //nolint:mnd // 64 bytes are enough for the signatures and the AVIF brands
+
+	n, err := io.ReadFull(src, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+
+	if seeker, ok := src.(io.Seeker); ok {
+		if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	buf = buf[:n]
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("\x89PNG\r\n\x1a\n")):
+		return MimeTypePNG, nil
+	case bytes.HasPrefix(buf, []byte{0xff, 0xd8, 0xff}):
+		return MimeTypeJPEG, nil
+	case len(buf) >= 12 && string(buf[0:4]) == "RIFF" && string(buf[8:12]) == "WEBP": //nolint:mnd
+		return MimeTypeWebP, nil
+	case isAVIF(buf):
+		return MimeTypeAVIF, nil
+	}
+
+	return "", nil
+}
+
+// isAVIF checks the ISO BMFF "ftyp" box: the major brand or one of the compatible brands must be "avif" (still
+// image) or "avis" (image sequence).
+func isAVIF(buf []byte) bool {
+	if len(buf) < 16 || string(buf[4:8]) != "ftyp" { //nolint:mnd
+		return false
+	}
+
+	var boxSize = min(int(binary.BigEndian.Uint32(buf[0:4])), len(buf))
+
+	// the major brand goes at 8, then the minor version (4 bytes), and the compatible brands list
+	for i := 8; i+4 <= boxSize; i += 4 {
+		if i == 12 { //nolint:mnd
+			continue // skip the minor version
+		}
+
+		if brand := string(buf[i : i+4]); brand == "avif" || brand == "avis" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -109,6 +109,78 @@ func TestIsImage(t *testing.T) {
 	}
 }
 
+func TestImageType(t *testing.T) {
+	t.Parallel()
+
+	fromFile := func(path string) io.Reader {
+		t.Helper()
+
+		data, err := os.ReadFile(path)
+		assertNoError(t, err)
+
+		return bytes.NewReader(data)
+	}
+
+	// ftypBox creates the AVIF file header ("ftyp" box) with the given brands
+	ftypBox := func(major string, compatible ...string) io.Reader {
+		var box = []byte{0, 0, 0, byte(16 + 4*len(compatible))}
+
+		box = append(box, "ftyp"+major+"\x00\x00\x00\x00"...)
+
+		for _, brand := range compatible {
+			box = append(box, brand...)
+		}
+
+		return bytes.NewReader(append(box, make([]byte, 32)...))
+	}
+
+	for name, tc := range map[string]struct {
+		giveReader func() io.Reader
+		wantType   string
+		wantErr    bool
+	}{
+		"empty reader":                {giveReader: func() io.Reader { return bytes.NewReader(nil) }},
+		"fake string":                 {giveReader: func() io.Reader { return bytes.NewReader([]byte("foo bar")) }},
+		"broken reader":               {giveReader: func() io.Reader { return brokenReader{} }, wantErr: true},
+		"html file":                   {giveReader: func() io.Reader { return fromFile("./testdata/html_file.html") }},
+		"bmp file (unsupported)":      {giveReader: func() io.Reader { return fromFile("./testdata/image.bmp") }},
+		"gif file (unsupported)":      {giveReader: func() io.Reader { return fromFile("./testdata/image.gif") }},
+		"jpg file":                    {giveReader: func() io.Reader { return fromFile("./testdata/image.jpg") }, wantType: validate.MimeTypeJPEG},
+		"png file":                    {giveReader: func() io.Reader { return fromFile("./testdata/image.png") }, wantType: validate.MimeTypePNG},
+		"webp file":                   {giveReader: func() io.Reader { return fromFile("./testdata/image.webp") }, wantType: validate.MimeTypeWebP},
+		"avif, major brand":           {giveReader: func() io.Reader { return ftypBox("avif", "mif1", "miaf") }, wantType: validate.MimeTypeAVIF},
+		"avif, compatible brand":      {giveReader: func() io.Reader { return ftypBox("mif1", "avif") }, wantType: validate.MimeTypeAVIF},
+		"avif, image sequence":        {giveReader: func() io.Reader { return ftypBox("avis", "msf1") }, wantType: validate.MimeTypeAVIF},
+		"other ftyp (e.g. mp4 video)": {giveReader: func() io.Reader { return ftypBox("isom", "iso2", "mp41") }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := validate.ImageType(tc.giveReader())
+
+			if tc.wantErr {
+				assertError(t, err)
+			} else {
+				assertNoError(t, err)
+			}
+
+			assertEqual(t, tc.wantType, res)
+		})
+	}
+
+	t.Run("seeker is reset", func(t *testing.T) {
+		t.Parallel()
+
+		var r = fromFile("./testdata/image.png").(*bytes.Reader)
+
+		_, err := validate.ImageType(r)
+		assertNoError(t, err)
+
+		pos, _ := r.Seek(0, io.SeekCurrent)
+		assertEqual(t, int64(0), pos)
+	})
+}
+
 func assertEqual[T comparable](t *testing.T, expected, actual T) {
 	t.Helper()
 
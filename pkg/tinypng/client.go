@@ -9,8 +9,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,7 +27,12 @@ var (
 	ErrBadRequest = errors.New("bad request (empty file or unsupported format)")
 )
 
-const shrinkEndpoint = "https://api.tinify.com/shrink" // API endpoint for image compression requests
+const (
+	// DefaultBaseURL is the base URL of the TinyPNG API.
+	DefaultBaseURL = "https://api.tinify.com"
+
+	shrinkPath = "/shrink" // API endpoint path for image compression requests
+)
 
 // httpClient defines an interface for making HTTP requests.
 type httpClient interface {
@@ -41,10 +48,20 @@ func WithHTTPClient(httpClient httpClient) ClientOption {
 	return func(c *Client) { c.httpClient = httpClient }
 }
 
+// WithBaseURL allows the use of a custom API base URL (e.g. a caching proxy or a local fake server), the
+// default is DefaultBaseURL. All the API URLs (including the compressed images URLs) are resolved relative to it.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) { c.baseURL = strings.TrimRight(baseURL, "/") }
+}
+
 // Client represents the TinyPNG API client.
 type Client struct {
 	httpClient httpClient // HTTP client used for making API requests
 	apiKey     string     // API key for authentication (obtain from <https://tinypng.com/developers>)
+	baseURL    string     // API base URL, without the trailing slash
+
+	usedQuota  atomic.Uint64 // last known number of used compressions (from the API responses)
+	quotaKnown atomic.Bool   // true if the usedQuota was received at least once
 }
 
 // NewClient creates a new TinyPNG client instance with the specified API key.
@@ -56,6 +73,10 @@ func NewClient(apiKey string, opts ...ClientOption) *Client {
 		opt(&c)
 	}
 
+	if c.baseURL == "" { // set default API base URL
+		c.baseURL = DefaultBaseURL
+	}
+
 	if c.httpClient == nil { // set default HTTP client
 		c.httpClient = &http.Client{
 			Timeout:   60 * time.Second,                         //nolint:mnd
@@ -69,6 +90,15 @@ func NewClient(apiKey string, opts ...ClientOption) *Client {
 // ApiKey returns the API key used by the client.
 func (c *Client) ApiKey() string { return c.apiKey }
 
+// BaseURL returns the API base URL used by the client.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// LastKnownUsedQuota returns the number of used compressions, reported by the latest API response (without
+// making any requests). The second return value is false if no responses with the quota usage were received yet.
+func (c *Client) LastKnownUsedQuota() (uint64, bool) {
+	return c.usedQuota.Load(), c.quotaKnown.Load()
+}
+
 // UsedQuota retrieves the number of compression requests made using the current API key.
 // Free-tier accounts are limited to 500 requests per month.
 func (c *Client) UsedQuota(ctx context.Context) (_ uint64, outErr error) {
@@ -79,7 +109,7 @@ func (c *Client) UsedQuota(ctx context.Context) (_ uint64, outErr error) {
 	}()
 
 	// Make a dummy image upload request to obtain the "Compression-Count" header from the response.
-	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, shrinkEndpoint, http.NoBody)
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+shrinkPath, http.NoBody)
 	if reqErr != nil {
 		return 0, reqErr
 	}
@@ -91,10 +121,10 @@ func (c *Client) UsedQuota(ctx context.Context) (_ uint64, outErr error) {
 		return 0, respErr
 	}
 
-	_ = resp.Body.Close() // We only need the headers, so we can safely discard the response body.
+	defer func() { _ = resp.Body.Close() }() // Only the headers are needed, unless the request is unauthorized.
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return 0, ErrUnauthorized
+		return 0, newAPIError(req, resp)
 	}
 
 	// Extract the compression count from the response headers.
@@ -116,7 +146,7 @@ func (c *Client) Compress(ctx context.Context, src io.Reader) (_ *Compressed, ou
 		}
 	}()
 
-	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, shrinkEndpoint, src)
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+shrinkPath, src)
 	if reqErr != nil {
 		return nil, reqErr
 	}
@@ -157,7 +187,7 @@ func (c *Client) Compress(ctx context.Context, src io.Reader) (_ *Compressed, ou
 			client: c,
 			Type:   p.Output.Type,
 			Size:   p.Output.Size,
-			URL:    p.Output.URL,
+			URL:    c.resolveURL(p.Output.URL),
 			Width:  p.Output.Width,
 			Height: p.Output.Height,
 		}
@@ -168,16 +198,7 @@ func (c *Client) Compress(ctx context.Context, src io.Reader) (_ *Compressed, ou
 
 		return &result, nil
 	case code >= 400 && code < 599:
-		switch code {
-		case http.StatusUnauthorized:
-			return nil, ErrUnauthorized
-		case http.StatusTooManyRequests:
-			return nil, ErrTooManyRequests
-		case http.StatusBadRequest:
-			return nil, ErrBadRequest
-		default:
-			return nil, parseRemoteError(resp.Body)
-		}
+		return nil, newAPIError(req, resp)
 	default:
 		return nil, fmt.Errorf("unexpected HTTP response status code (%d)", code)
 	}
@@ -202,11 +223,54 @@ type (
 		//	- `location` - GPS location
 		//	- `creation` - creation date
 		Preserve []string
+
+		// the compressed image will be resized to the specified dimensions (nil means no resizing)
+		Resize *resizeOptions
+
+		// the compressed image will be converted to one of the specified MIME types (nil means no conversion)
+		Convert *convertOptions
+
+		// the transformations applied to the converted image (nil means no transformations)
+		Transform *transformOptions
+	}
+
+	convertOptions struct {
+		Type []string `json:"type"` // the smallest of the listed types is chosen by the API
+	}
+
+	transformOptions struct {
+		Background string `json:"background"` // "white", "black" or a hex color, e.g. "#000000"
+	}
+
+	resizeOptions struct {
+		Method ResizeMethod `json:"method"`
+		Width  uint32       `json:"width,omitempty"`
+		Height uint32       `json:"height,omitempty"`
 	}
 
 	DownloadOption func(*downloadOptions)
 )
 
+// ResizeMethod defines the way the image is resized by the TinyPNG API.
+type ResizeMethod string
+
+const (
+	// ResizeScale scales the image down proportionally. Exactly one of the width or height must be provided.
+	ResizeScale ResizeMethod = "scale"
+
+	// ResizeFit scales the image down proportionally so that it fits within the given dimensions.
+	// Both width and height must be provided.
+	ResizeFit ResizeMethod = "fit"
+
+	// ResizeCover scales the image proportionally and crops it if necessary so that the result has exactly
+	// the given dimensions. Both width and height must be provided.
+	ResizeCover ResizeMethod = "cover"
+
+	// ResizeThumb is a more advanced implementation of the ResizeCover that also detects cut out images with
+	// plain backgrounds. Both width and height must be provided.
+	ResizeThumb ResizeMethod = "thumb"
+)
+
 // WithDownloadPreserveCopyright specifies that the copyright information should be preserved.
 func WithDownloadPreserveCopyright() DownloadOption {
 	return func(o *downloadOptions) { o.Preserve = append(o.Preserve, "copyright") }
@@ -222,9 +286,39 @@ func WithDownloadPreserveCreation() DownloadOption {
 	return func(o *downloadOptions) { o.Preserve = append(o.Preserve, "creation") }
 }
 
+// WithDownloadResize specifies that the compressed image should be resized using the given method. A zero
+// width or height means the dimension is not set (which is required for the ResizeScale method).
+//
+// Note: resizing counts as an additional compression.
+func WithDownloadResize(method ResizeMethod, width, height uint32) DownloadOption {
+	return func(o *downloadOptions) { o.Resize = &resizeOptions{Method: method, Width: width, Height: height} }
+}
+
+// WithDownloadConvert specifies that the compressed image should be converted to one of the given MIME types
+// (e.g. "image/webp", "image/avif", "image/png" or "image/jpeg"). If multiple types are provided, the API
+// returns the smallest one (the resulting type is reported in the Content-Type response header).
+//
+// Note: converting counts as an additional compression.
+func WithDownloadConvert(mimeTypes ...string) DownloadOption {
+	return func(o *downloadOptions) {
+		if o.Convert == nil {
+			o.Convert = &convertOptions{}
+		}
+
+		o.Convert.Type = append(o.Convert.Type, mimeTypes...)
+	}
+}
+
+// WithDownloadBackground specifies the background color used to fill the transparent areas of the converted
+// image (required when converting an image with transparency to a format that does not support it, e.g. JPEG).
+// The color can be "white", "black" or a hex value, e.g. "#0a0b0c".
+func WithDownloadBackground(color string) DownloadOption {
+	return func(o *downloadOptions) { o.Transform = &transformOptions{Background: color} }
+}
+
 // Download retrieves the compressed image from the TinyPNG servers and writes it to the specified destination.
 // If the provided destination implements io.Closer, it will be closed automatically by the HTTP client.
-func (c Compressed) Download(ctx context.Context, to io.Writer, opt ...DownloadOption) (outErr error) { //nolint:funlen
+func (c Compressed) Download(ctx context.Context, to io.Writer, opt ...DownloadOption) (outErr error) {
 	defer func() { // Wrap the error with a package-specific prefix.
 		if outErr != nil {
 			outErr = fmt.Errorf("tinypng: %w", outErr)
@@ -236,22 +330,145 @@ func (c Compressed) Download(ctx context.Context, to io.Writer, opt ...DownloadO
 		o(&opts)
 	}
 
+	req, reqErr := c.newOutputRequest(ctx, opts, nil)
+	if reqErr != nil {
+		return reqErr
+	}
+
+	resp, respErr := c.client.httpClient.Do(req)
+	if respErr != nil {
+		return respErr
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return outputResponseError(req, resp)
+	}
+
+	_, _ = c.client.extractCompressionCount(resp.Header) // resizing and conversion are counted as compressions
+
+	_, err := io.Copy(to, resp.Body)
+
+	return err
+}
+
+type (
+	// StoreTarget describes a cloud storage the compressed image can be saved to (see [Compressed.Store]).
+	StoreTarget interface {
+		storeOptions() any // returns the "store" object for the API request
+	}
+
+	// S3Store is an Amazon S3 (or S3-compatible) storage target.
+	S3Store struct {
+		AccessKeyID     string            // AWS access key ID.
+		SecretAccessKey string            // AWS secret access key.
+		Region          string            // AWS region, e.g. "us-west-1".
+		Path            string            // Bucket name and the object path, e.g. "bucket/images/file.png".
+		Headers         map[string]string // Optional headers for the stored object, e.g. "Cache-Control".
+		ACL             string            // Optional ACL setting ("no-acl" to disable the default "public-read").
+	}
+
+	// GCSStore is a Google Cloud Storage target.
+	GCSStore struct {
+		AccessToken string            // GCP access token (OAuth 2.0).
+		Path        string            // Bucket name and the object path, e.g. "bucket/images/file.png".
+		Headers     map[string]string // Optional headers for the stored object, e.g. "Cache-Control".
+	}
+)
+
+// Ensures that the storage targets implement the StoreTarget interface.
+var (
+	_ StoreTarget = S3Store{}
+	_ StoreTarget = GCSStore{}
+)
+
+func (s S3Store) storeOptions() any {
+	return struct {
+		Service         string            `json:"service"`
+		AccessKeyID     string            `json:"aws_access_key_id"`
+		SecretAccessKey string            `json:"aws_secret_access_key"`
+		Region          string            `json:"region"`
+		Path            string            `json:"path"`
+		Headers         map[string]string `json:"headers,omitempty"`
+		ACL             string            `json:"acl,omitempty"`
+	}{"s3", s.AccessKeyID, s.SecretAccessKey, s.Region, s.Path, s.Headers, s.ACL}
+}
+
+func (s GCSStore) storeOptions() any {
+	return struct {
+		Service     string            `json:"service"`
+		AccessToken string            `json:"gcp_access_token"`
+		Path        string            `json:"path"`
+		Headers     map[string]string `json:"headers,omitempty"`
+	}{"gcs", s.AccessToken, s.Path, s.Headers}
+}
+
+// Store saves the compressed image directly to the cloud storage (without downloading it) and returns the
+// URL of the stored image (the value of the "Location" response header). The download options (e.g. resizing
+// or conversion) are applied to the stored image.
+func (c Compressed) Store(ctx context.Context, to StoreTarget, opt ...DownloadOption) (_ string, outErr error) {
+	defer func() { // Wrap the error with a package-specific prefix.
+		if outErr != nil {
+			outErr = fmt.Errorf("tinypng: %w", outErr)
+		}
+	}()
+
+	if to == nil {
+		return "", errors.New("store target is not specified")
+	}
+
+	var opts downloadOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+
+	req, reqErr := c.newOutputRequest(ctx, opts, to.storeOptions())
+	if reqErr != nil {
+		return "", reqErr
+	}
+
+	resp, respErr := c.client.httpClient.Do(req)
+	if respErr != nil {
+		return "", respErr
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if code := resp.StatusCode; code != http.StatusOK && code != http.StatusCreated {
+		return "", outputResponseError(req, resp)
+	}
+
+	return resp.Header.Get("Location"), nil
+}
+
+// newOutputRequest creates an HTTP request for the compressed image URL. If no options (and no store object)
+// are provided, a simple GET request is created, otherwise a POST request with the JSON payload is used.
+func (c Compressed) newOutputRequest(ctx context.Context, opts downloadOptions, store any) (*http.Request, error) {
 	var req *http.Request
 
 	switch {
-	case len(opts.Preserve) > 0:
+	case len(opts.Preserve) > 0 || opts.Resize != nil || opts.Convert != nil || opts.Transform != nil || store != nil:
 		j, err := json.Marshal(struct {
-			Preserve []string `json:"preserve"`
+			Preserve  []string          `json:"preserve,omitempty"`
+			Resize    *resizeOptions    `json:"resize,omitempty"`
+			Convert   *convertOptions   `json:"convert,omitempty"`
+			Transform *transformOptions `json:"transform,omitempty"`
+			Store     any               `json:"store,omitempty"`
 		}{
-			Preserve: opts.Preserve,
+			Preserve:  opts.Preserve,
+			Resize:    opts.Resize,
+			Convert:   opts.Convert,
+			Transform: opts.Transform,
+			Store:     store,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		req, err = http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(j))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		req.Header.Set("Content-Type", "application/json")
@@ -260,59 +477,152 @@ func (c Compressed) Download(ctx context.Context, to io.Writer, opt ...DownloadO
 
 		req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.URL, http.NoBody)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	req.SetBasicAuth("api", c.client.apiKey)
 
-	resp, respErr := c.client.httpClient.Do(req)
-	if respErr != nil {
-		return respErr
-	}
-
-	defer func() { _ = resp.Body.Close() }()
+	return req, nil
+}
 
+// outputResponseError converts an unsuccessful response for the compressed image URL into a Go error.
+func outputResponseError(req *http.Request, resp *http.Response) error {
 	switch code := resp.StatusCode; {
-	case code == http.StatusOK:
-		_, err := io.Copy(to, resp.Body)
-
-		return err
 	case code >= 400 && code < 599:
-		switch code {
-		case http.StatusUnauthorized:
-			return ErrUnauthorized
-		case http.StatusTooManyRequests:
-			return ErrTooManyRequests
-		default:
-			return parseRemoteError(resp.Body)
-		}
+		return newAPIError(req, resp)
 	default:
 		return fmt.Errorf("unexpected HTTP response status code (%d)", code)
 	}
 }
 
-// parseRemoteError decodes an error response from TinyPNG and converts it into a Go error.
-func parseRemoteError(content io.Reader) error {
-	var e struct {
+// APIError is an unsuccessful response of the TinyPNG API. Use [errors.As] to access its details, or [errors.Is]
+// with ErrUnauthorized, ErrTooManyRequests and ErrBadRequest to check the error kind.
+type APIError struct {
+	StatusCode int    // HTTP status code of the response, e.g. 415.
+	Code       string // Error code from the response payload, e.g. "Unsupported media type" (may be empty).
+	Message    string // Error message from the response payload (may be empty).
+	URL        string // URL of the request.
+
+	retryAfter time.Duration // delay, requested by the server (the Retry-After header)
+}
+
+// newAPIError creates the error for the unsuccessful response, with the error code and message decoded from the
+// response body. If the body can't be decoded, the decoding error is used as the message, unless the status code
+// alone describes the error kind (then the body is ignored, since it may be empty or meaningless).
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	var e = APIError{StatusCode: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header)}
+
+	if req != nil && req.URL != nil {
+		e.URL = req.URL.String()
+	}
+
+	var payload struct {
 		Error   string `json:"error"`
 		Message string `json:"message"`
 	}
 
-	if err := json.NewDecoder(content).Decode(&e); err != nil {
-		return fmt.Errorf("error decoding failed: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		if e.kind() == nil {
+			e.Message = fmt.Sprintf("error decoding failed: %s", err)
+		}
+	} else {
+		e.Code, e.Message = payload.Error, payload.Message
+	}
+
+	return &e
+}
+
+// Error returns the error code and message from the response payload, if available. Otherwise, the error kind
+// (or the status code) is described.
+func (e *APIError) Error() string {
+	var msg = strings.Trim(e.Message, ". ")
+
+	switch {
+	case e.Code != "" && msg != "":
+		return fmt.Sprintf("%s (%s)", e.Code, msg)
+	case e.Code != "":
+		return e.Code
+	case msg != "":
+		return msg
+	}
+
+	if kind := e.kind(); kind != nil {
+		return kind.Error()
+	}
+
+	return fmt.Sprintf("unexpected HTTP response status code (%d)", e.StatusCode)
+}
+
+// Is reports whether the error is of the given kind (ErrUnauthorized, ErrTooManyRequests or ErrBadRequest).
+func (e *APIError) Is(target error) bool {
+	var kind = e.kind()
+
+	return kind != nil && kind == target
+}
+
+// RetryAfter returns the delay, requested by the server before the next request (zero if unknown).
+func (e *APIError) RetryAfter() time.Duration { return e.retryAfter }
+
+// kind returns the sentinel error matching the status code, or nil.
+func (e *APIError) kind() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses the Retry-After response header (delay in seconds or HTTP date). Zero is returned if the
+// header is missing or invalid.
+func parseRetryAfter(headers http.Header) time.Duration {
+	if v := strings.TrimSpace(headers.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return time.Duration(secs) * time.Second
+		} else if at, dErr := http.ParseTime(v); dErr == nil {
+			return max(0, time.Until(at))
+		}
 	}
 
-	return fmt.Errorf("%s (%s)", e.Error, strings.Trim(e.Message, ". "))
+	return 0
 }
 
-// extractCompressionCount extracts `compression-count` header value from HTTP response headers.
+// resolveURL resolves the URL returned by the API relative to the client base URL (only the path and query are
+// taken from the original URL). If the URL cannot be parsed, it is returned as is.
+func (c *Client) resolveURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Path == "" && u.RawQuery == "") {
+		return raw
+	}
+
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return raw
+	}
+
+	var resolved = base.JoinPath(u.Path)
+
+	resolved.RawQuery = u.RawQuery
+
+	return resolved.String()
+}
+
+// extractCompressionCount extracts `compression-count` header value from HTTP response headers. The extracted
+// value is remembered as the last known quota usage of the client.
 func (c *Client) extractCompressionCount(headers http.Header) (uint64, error) {
 	const headerName = "Compression-Count"
 
 	if val, ok := headers[headerName]; ok && len(val) > 0 {
 		count, err := strconv.ParseUint(val[0], 10, 64)
 		if err == nil {
+			c.usedQuota.Store(count)
+			c.quotaKnown.Store(true)
+
 			return count, nil
 		}
 
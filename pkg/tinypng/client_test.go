@@ -8,9 +8,12 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
 )
@@ -137,6 +140,49 @@ func TestClient_UsedQuota(t *testing.T) {
 	})
 }
 
+func TestClient_WithBaseURL(t *testing.T) {
+	t.Parallel()
+
+	var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case "http://proxy.local/tinify/shrink":
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Compression-Count": {"1"}},
+				Body: io.NopCloser(strings.NewReader(`{
+					"output":{"url":"https://api.tinify.com/output/someRandomResultImageHash?foo=bar"}
+				}`)),
+			}, nil
+
+		case "http://proxy.local/tinify/output/someRandomResultImageHash?foo=bar":
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBuffer(compressedImage)),
+			}, nil
+
+		default:
+			return nil, errors.New("unexpected request: " + req.URL.String())
+		}
+	}
+
+	var client = tinypng.NewClient("foo-key",
+		tinypng.WithHTTPClient(httpMock),
+		tinypng.WithBaseURL("http://proxy.local/tinify/"),
+	)
+
+	assertEqual(t, "http://proxy.local/tinify", client.BaseURL())
+
+	info, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+	assertNoError(t, err)
+	assertEqual(t, "http://proxy.local/tinify/output/someRandomResultImageHash?foo=bar", info.URL)
+
+	out := bytes.NewBuffer(nil)
+	assertNoError(t, info.Download(t.Context(), out))
+	assertSlicesEqual(t, compressedImage, out.Bytes())
+
+	assertEqual(t, tinypng.DefaultBaseURL, tinypng.NewClient("").BaseURL())
+}
+
 func TestClient_Compress(t *testing.T) {
 	t.Parallel()
 
@@ -269,6 +315,38 @@ func TestClient_Compress(t *testing.T) {
 		assertErrorIs(t, err, tinypng.ErrTooManyRequests)
 	})
 
+	t.Run("too many requests (with retry-after)", func(t *testing.T) {
+		t.Parallel()
+
+		for give, want := range map[string]time.Duration{
+			"7":       7 * time.Second,
+			"":        0,
+			"invalid": 0,
+		} {
+			var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Header:     http.Header{"Retry-After": {give}},
+					Body:       io.NopCloser(bytes.NewReader([]byte{})),
+					StatusCode: http.StatusTooManyRequests,
+				}, nil
+			}
+
+			_, err := tinypng.
+				NewClient("", tinypng.WithHTTPClient(httpMock)).
+				Compress(t.Context(), bytes.NewBuffer(srcImage))
+
+			assertErrorIs(t, err, tinypng.ErrTooManyRequests)
+
+			var ra interface{ RetryAfter() time.Duration }
+
+			if !errors.As(err, &ra) {
+				t.Fatal("expected the error to provide the Retry-After delay")
+			}
+
+			assertEqual(t, want, ra.RetryAfter())
+		}
+	})
+
 	t.Run("bad request", func(t *testing.T) {
 		t.Parallel()
 
@@ -324,6 +402,79 @@ func TestClient_Compress(t *testing.T) {
 		assertNil(t, info)
 		assertError(t, err)
 		assertErrorContains(t, err, "tinypng: Foo (bar baz)")
+
+		var apiErr *tinypng.APIError
+
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("expected API error, got %T", err)
+		}
+
+		assertEqual(t, http.StatusTeapot, apiErr.StatusCode)
+		assertEqual(t, "Foo", apiErr.Code)
+		assertEqual(t, "bar baz.", apiErr.Message)
+		assertEqual(t, tinypng.DefaultBaseURL+"/shrink", apiErr.URL)
+	})
+
+	t.Run("api error kinds", func(t *testing.T) {
+		t.Parallel()
+
+		for code, wantKind := range map[int]error{
+			http.StatusUnauthorized:         tinypng.ErrUnauthorized,
+			http.StatusTooManyRequests:      tinypng.ErrTooManyRequests,
+			http.StatusBadRequest:           tinypng.ErrBadRequest,
+			http.StatusUnsupportedMediaType: nil,
+			http.StatusServiceUnavailable:   nil,
+		} {
+			t.Run(strconv.Itoa(code), func(t *testing.T) {
+				t.Parallel()
+
+				var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":"Foo","message":"bar"}`))),
+						StatusCode: code,
+					}, nil
+				}
+
+				_, err := tinypng.NewClient("", tinypng.WithHTTPClient(httpMock)).
+					Compress(t.Context(), bytes.NewBuffer(srcImage))
+
+				var apiErr *tinypng.APIError
+
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected API error, got %T", err)
+				}
+
+				assertEqual(t, code, apiErr.StatusCode)
+				assertEqual(t, "Foo", apiErr.Code)
+				assertEqual(t, "bar", apiErr.Message)
+				assertEqual(t, "tinypng: Foo (bar)", err.Error())
+
+				for _, kind := range []error{tinypng.ErrUnauthorized, tinypng.ErrTooManyRequests, tinypng.ErrBadRequest} {
+					assertEqual(t, kind == wantKind, errors.Is(err, kind))
+				}
+			})
+		}
+	})
+
+	t.Run("api error kind without payload", func(t *testing.T) {
+		t.Parallel()
+
+		var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{Body: io.NopCloser(bytes.NewReader(nil)), StatusCode: http.StatusUnauthorized}, nil
+		}
+
+		_, err := tinypng.NewClient("", tinypng.WithHTTPClient(httpMock)).
+			Compress(t.Context(), bytes.NewBuffer(srcImage))
+
+		var apiErr *tinypng.APIError
+
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("expected API error, got %T", err)
+		}
+
+		assertEqual(t, "", apiErr.Code)
+		assertEqual(t, "", apiErr.Message)
+		assertEqual(t, "tinypng: "+tinypng.ErrUnauthorized.Error(), err.Error())
 	})
 
 	t.Run("4xx error with wrong json", func(t *testing.T) {
@@ -426,6 +577,150 @@ func TestCompressed_Download(t *testing.T) {
 		assertSlicesEqual(t, compressedImage, out.Bytes())
 	})
 
+	t.Run("resize", func(t *testing.T) {
+		t.Parallel()
+
+		var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+			switch req.URL.String() {
+			case "https://api.tinify.com/shrink":
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Header:     http.Header{"Compression-Count": {"123454321"}},
+					Body: io.NopCloser(bytes.NewReader([]byte(`{
+						"output":{
+							"url":"https://api.tinify.com/output/someRandomResultImageHashResize"
+						}
+					}`))),
+				}, nil
+
+			case "https://api.tinify.com/output/someRandomResultImageHashResize":
+				assertEqual(t, http.MethodPost, req.Method)
+
+				body, _ := io.ReadAll(req.Body)
+
+				assertEqual(t, "application/json", req.Header.Get("Content-Type"))
+				assertEqual(t, `{"preserve":["creation"],"resize":{"method":"fit","width":150,"height":100}}`, string(body))
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(compressedImage)),
+				}, nil
+
+			default:
+				return nil, errors.New("unexpected request")
+			}
+		}
+
+		info, err := tinypng.
+			NewClient("bar-key", tinypng.WithHTTPClient(httpMock)).
+			Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		err = info.Download(
+			t.Context(),
+			out,
+			tinypng.WithDownloadPreserveCreation(),
+			tinypng.WithDownloadResize(tinypng.ResizeFit, 150, 100),
+		)
+
+		assertNoError(t, err)
+		assertSlicesEqual(t, compressedImage, out.Bytes())
+	})
+
+	t.Run("resize (scale, single dimension)", func(t *testing.T) {
+		t.Parallel()
+
+		var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+			switch req.URL.String() {
+			case "https://api.tinify.com/shrink":
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Body: io.NopCloser(bytes.NewReader([]byte(`{
+						"output":{
+							"url":"https://api.tinify.com/output/someRandomResultImageHashScale"
+						}
+					}`))),
+				}, nil
+
+			case "https://api.tinify.com/output/someRandomResultImageHashScale":
+				body, _ := io.ReadAll(req.Body)
+
+				assertEqual(t, `{"resize":{"method":"scale","width":64}}`, string(body))
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(compressedImage)),
+				}, nil
+
+			default:
+				return nil, errors.New("unexpected request")
+			}
+		}
+
+		info, err := tinypng.
+			NewClient("bar-key", tinypng.WithHTTPClient(httpMock)).
+			Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		assertNoError(t, info.Download(t.Context(), io.Discard, tinypng.WithDownloadResize(tinypng.ResizeScale, 64, 0)))
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		t.Parallel()
+
+		var httpMock httpClientFunc = func(req *http.Request) (*http.Response, error) {
+			switch req.URL.String() {
+			case "https://api.tinify.com/shrink":
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Body: io.NopCloser(bytes.NewReader([]byte(`{
+						"output":{
+							"url":"https://api.tinify.com/output/someRandomResultImageHashConvert"
+						}
+					}`))),
+				}, nil
+
+			case "https://api.tinify.com/output/someRandomResultImageHashConvert":
+				assertEqual(t, http.MethodPost, req.Method)
+
+				body, _ := io.ReadAll(req.Body)
+
+				assertEqual(t, "application/json", req.Header.Get("Content-Type"))
+				assertEqual(t,
+					`{"convert":{"type":["image/webp","image/avif"]},"transform":{"background":"#000000"}}`,
+					string(body),
+				)
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"image/webp"}},
+					Body:       io.NopCloser(bytes.NewBuffer(compressedImage)),
+				}, nil
+
+			default:
+				return nil, errors.New("unexpected request")
+			}
+		}
+
+		info, err := tinypng.
+			NewClient("bar-key", tinypng.WithHTTPClient(httpMock)).
+			Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		out := bytes.NewBuffer(nil)
+		err = info.Download(
+			t.Context(),
+			out,
+			tinypng.WithDownloadConvert("image/webp"),
+			tinypng.WithDownloadConvert("image/avif"),
+			tinypng.WithDownloadBackground("#000000"),
+		)
+
+		assertNoError(t, err)
+		assertSlicesEqual(t, compressedImage, out.Bytes())
+	})
+
 	t.Run("unauthorized", func(t *testing.T) {
 		t.Parallel()
 
@@ -546,6 +841,135 @@ func TestCompressed_Download(t *testing.T) {
 	})
 }
 
+func TestCompressed_Store(t *testing.T) {
+	t.Parallel()
+
+	// newClient starts a test server that stands in for the API (the "shrink" requests are handled by the server
+	// itself, and the output requests are passed to the given handler) and creates a client that uses it
+	var newClient = func(t *testing.T, output http.HandlerFunc) *tinypng.Client {
+		t.Helper()
+
+		var mux = http.NewServeMux()
+
+		mux.HandleFunc("POST /shrink", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"output":{"url":"https://api.tinify.com/output/someRandomResultImageHash"}}`))
+		})
+		mux.Handle("/output/", output)
+
+		var srv = httptest.NewServer(mux)
+
+		t.Cleanup(srv.Close)
+
+		return tinypng.NewClient("bar-key", tinypng.WithBaseURL(srv.URL), tinypng.WithHTTPClient(srv.Client()))
+	}
+
+	t.Run("s3", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(t, func(w http.ResponseWriter, req *http.Request) {
+			assertEqual(t, http.MethodPost, req.Method)
+			assertEqual(t, "/output/someRandomResultImageHash", req.URL.Path)
+			assertEqual(t, authHeaderValue(t, "bar-key"), req.Header.Get("Authorization"))
+			assertEqual(t, "application/json", req.Header.Get("Content-Type"))
+
+			body, _ := io.ReadAll(req.Body)
+
+			assertEqual(t, `{"resize":{"method":"scale","width":64},"store":{"service":"s3",`+
+				`"aws_access_key_id":"key-id","aws_secret_access_key":"secret","region":"us-west-1",`+
+				`"path":"bucket/images/file.png","headers":{"Cache-Control":"public, max-age=31536000"}}}`,
+				string(body),
+			)
+
+			w.Header().Set("Location", "https://s3-us-west-1.amazonaws.com/bucket/images/file.png")
+			w.WriteHeader(http.StatusOK)
+		})
+
+		comp, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		location, err := comp.Store(t.Context(), tinypng.S3Store{
+			AccessKeyID:     "key-id",
+			SecretAccessKey: "secret",
+			Region:          "us-west-1",
+			Path:            "bucket/images/file.png",
+			Headers:         map[string]string{"Cache-Control": "public, max-age=31536000"},
+		}, tinypng.WithDownloadResize(tinypng.ResizeScale, 64, 0))
+
+		assertNoError(t, err)
+		assertEqual(t, "https://s3-us-west-1.amazonaws.com/bucket/images/file.png", location)
+	})
+
+	t.Run("gcs", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(t, func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+
+			assertEqual(t, `{"store":{"service":"gcs","gcp_access_token":"token","path":"bucket/file.png"}}`, string(body))
+
+			w.Header().Set("Location", "https://storage.googleapis.com/bucket/file.png")
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		comp, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		location, err := comp.Store(t.Context(), tinypng.GCSStore{AccessToken: "token", Path: "bucket/file.png"})
+
+		assertNoError(t, err)
+		assertEqual(t, "https://storage.googleapis.com/bucket/file.png", location)
+	})
+
+	t.Run("no target", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(t, func(http.ResponseWriter, *http.Request) {
+			t.Error("unexpected request")
+		})
+
+		comp, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		location, err := comp.Store(t.Context(), nil)
+
+		assertEqual(t, "", location)
+		assertErrorContains(t, err, "tinypng:", "store target is not specified")
+	})
+
+	t.Run("4xx error", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"BadRequest","message":"Access denied."}`))
+		})
+
+		comp, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		location, err := comp.Store(t.Context(), tinypng.GCSStore{AccessToken: "token", Path: "bucket/file.png"})
+
+		assertEqual(t, "", location)
+		assertEqual(t, "tinypng: BadRequest (Access denied)", err.Error())
+	})
+
+	t.Run("too many requests", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		comp, err := client.Compress(t.Context(), bytes.NewBuffer(srcImage))
+		assertNoError(t, err)
+
+		_, err = comp.Store(t.Context(), tinypng.S3Store{Path: "bucket/file.png"})
+
+		assertErrorIs(t, err, tinypng.ErrTooManyRequests)
+	})
+}
+
 func assertEqual[T comparable](t *testing.T, expected, actual T) {
 	t.Helper()
 
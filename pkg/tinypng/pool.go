@@ -1,57 +1,431 @@
 package tinypng
 
-import "sync"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoClients is returned when the pool has no (more) clients, e.g. all the keys were revoked or retired.
+	ErrNoClients = errors.New("no valid API keys available")
+
+	// ErrQuotaLimitReached is the reason of retiring the key, which reached the pool quota limit.
+	ErrQuotaLimitReached = errors.New("quota limit reached")
+
+	// ErrRevoked is the reason of retiring the key, which was revoked explicitly.
+	ErrRevoked = errors.New("revoked")
+)
+
+// Defaults for the keys cool-down (after the ErrTooManyRequests errors).
+const (
+	DefaultCoolDown          = 30 * time.Second
+	DefaultCoolDownMaxStrike = 3
+)
+
+// PoolStrategy defines how the pool chooses the client (API key) for the next request.
+type PoolStrategy string
+
+const (
+	// StrategyMostRemaining chooses the key with the most remaining quota (the least used one, according to the
+	// last known quota usage). Ties are broken by the number of in-flight requests. This is the default strategy.
+	StrategyMostRemaining PoolStrategy = "most-remaining"
+
+	// StrategyRandom chooses a random key.
+	StrategyRandom PoolStrategy = "random"
+
+	// StrategyRoundRobin chooses the keys one by one, in the order they were added.
+	StrategyRoundRobin PoolStrategy = "round-robin"
+
+	// StrategyLeastInFlight chooses the key with the least number of in-flight requests (leases).
+	StrategyLeastInFlight PoolStrategy = "least-in-flight"
+)
+
+// PoolStrategies returns all the supported pool strategies.
+func PoolStrategies() []PoolStrategy {
+	return []PoolStrategy{StrategyMostRemaining, StrategyRandom, StrategyRoundRobin, StrategyLeastInFlight}
+}
 
 type ClientsPool struct {
-	opts []ClientOption
+	opts          []ClientOption
+	quotaLimit    uint64 // 0 means no limit
+	strategy      PoolStrategy
+	maxInFlight   uint          // 0 means no limit
+	coolDown      time.Duration // used when the server does not provide the Retry-After delay
+	coolDownLimit uint          // number of consecutive cool-downs before the key is retired
+
+	mu        sync.Mutex
+	keys      []string             // keys in the order they were added (used to break ties)
+	clients   map[string]*Client   // nil values for the uninitialized clients
+	inFlight  map[string]uint      // number of active leases per key
+	coolUntil map[string]time.Time // keys on the cool-down (not used until the time)
+	strikes   map[string]uint      // number of consecutive cool-downs per key
+	retired   []RetiredKey         // keys removed from the pool
+	next      int                  // the next key index for the round-robin strategy
+	changed   chan struct{}        // closed (and replaced) when a lease is released or a key is removed
+}
+
+// RetiredKey is the API key, removed from the pool, with the reason of removal.
+type RetiredKey struct {
+	Key    string
+	Reason error // ErrUnauthorized, ErrTooManyRequests, ErrQuotaLimitReached or ErrRevoked
+}
+
+// PoolOption is a functional option used to configure the ClientsPool instance.
+type PoolOption func(*ClientsPool)
 
-	mu   sync.Mutex
-	keys map[string]*Client
+// WithPoolClientOptions sets the options used to create the clients in the pool.
+func WithPoolClientOptions(opts ...ClientOption) PoolOption {
+	return func(p *ClientsPool) { p.opts = append(p.opts, opts...) }
+}
+
+// WithPoolQuotaLimit sets the monthly compressions limit per API key. Keys that reach the limit (according
+// to the last known quota usage) are retired from the pool before they hit the API limit. Zero means no limit.
+func WithPoolQuotaLimit(limit uint64) PoolOption {
+	return func(p *ClientsPool) { p.quotaLimit = limit }
+}
+
+// WithPoolStrategy sets the strategy used to choose the client (the default is StrategyMostRemaining).
+func WithPoolStrategy(s PoolStrategy) PoolOption {
+	return func(p *ClientsPool) { p.strategy = s }
+}
+
+// WithPoolMaxInFlight sets the maximum number of in-flight requests (active leases) per API key. Acquire blocks
+// when all the keys are saturated. Zero means no limit.
+func WithPoolMaxInFlight(n uint) PoolOption {
+	return func(p *ClientsPool) { p.maxInFlight = n }
+}
+
+// WithPoolCoolDown sets the cool-down delay for the keys that got ErrTooManyRequests (used when the server
+// does not provide the Retry-After delay), and the number of consecutive cool-downs allowed for the key. The key
+// is retired on the next ErrTooManyRequests after that (e.g. on the 4th one in a row, if maxStrikes is 3).
+func WithPoolCoolDown(d time.Duration, maxStrikes uint) PoolOption {
+	return func(p *ClientsPool) { p.coolDown, p.coolDownLimit = d, maxStrikes }
 }
 
 // NewClientsPool initializes a new pool of clients using the given API keys.
 // Additional options can be provided to customize the clients.
 func NewClientsPool(apiKeys []string, opts ...ClientOption) *ClientsPool {
+	return NewClientsPoolWithOptions(apiKeys, WithPoolClientOptions(opts...))
+}
+
+// NewClientsPoolWithOptions initializes a new pool of clients using the given API keys.
+// Additional options can be provided to customize the pool (and the clients, see WithPoolClientOptions).
+func NewClientsPoolWithOptions(apiKeys []string, opts ...PoolOption) *ClientsPool {
 	var pool = ClientsPool{
-		opts: opts,
-		keys: make(map[string]*Client, len(apiKeys)), // initialize the map to avoid nil map assignment
+		strategy:      StrategyMostRemaining,
+		coolDown:      DefaultCoolDown,
+		coolDownLimit: DefaultCoolDownMaxStrike,
+		keys:          make([]string, 0, len(apiKeys)),
+		clients:       make(map[string]*Client, len(apiKeys)), // initialize the map to avoid nil map assignment
+		inFlight:      make(map[string]uint, len(apiKeys)),
+		coolUntil:     make(map[string]time.Time),
+		strikes:       make(map[string]uint),
+		changed:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&pool)
 	}
 
 	for _, key := range apiKeys {
-		pool.keys[key] = nil // prepopulate the map with keys and nil clients
+		if _, exists := pool.clients[key]; exists {
+			continue // skip duplicates
+		}
+
+		pool.keys = append(pool.keys, key)
+		pool.clients[key] = nil // prepopulate the map with keys and nil clients
 	}
 
 	return &pool
 }
 
-// Get retrieves a random client from the pool.
+// Get retrieves a client from the pool, chosen using the pool strategy. The in-flight requests limit is
+// not applied (use Acquire for that).
 //
 // If the pool is empty, it returns nil and false as the last return value. If the client for a key is
-// uninitialized, it creates a new one and returns it.
+// uninitialized, it creates a new one and returns it. Keys that reached the quota limit are removed from the pool.
 // The returned cleanup function should be called when the client is no longer needed, allowing the key
 // to be removed from the pool.
 func (p *ClientsPool) Get() (*Client, func(), bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// iterate over the map to get a random key (order of map iteration is not guaranteed)
-	for key, c := range p.keys {
+	key, found := p.pick(false)
+	if !found {
+		return nil, func() {}, false
+	}
+
+	// return the client along with a cleanup function that removes the key from the pool
+	return p.clients[key], func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// remove key from the pool when the client is no longer needed so any next Get() call will
+		// return a client with another key
+		p.remove(key, ErrRevoked)
+	}, true
+}
+
+// Lease is a client, leased from the pool. It must be released (or revoked) when the client is no longer needed.
+type Lease struct {
+	Client *Client
+
+	pool *ClientsPool
+	key  string
+	once sync.Once
+}
+
+// Release returns the client to the pool (the request was not rejected because of the key). It's safe to call it
+// multiple times.
+func (l *Lease) Release() { l.release(nil) }
+
+// Revoke removes the key from the pool (e.g. because it's invalid) and releases the lease. It's safe to call it
+// multiple times.
+func (l *Lease) Revoke() { l.release(ErrRevoked) }
+
+// Fail releases the lease after the request failed with the given error:
+//   - ErrUnauthorized removes the key from the pool permanently
+//   - ErrTooManyRequests puts the key on the cool-down (using the Retry-After delay if the error provides it), and
+//     removes it after too many consecutive cool-downs
+//
+// Other errors are not related to the key, so the lease is simply released. It's safe to call it multiple times.
+func (l *Lease) Fail(err error) { l.release(err) }
+
+func (l *Lease) release(reason error) {
+	l.once.Do(func() {
+		var p = l.pool
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if n := p.inFlight[l.key]; n > 0 {
+			p.inFlight[l.key] = n - 1
+		}
+
+		switch {
+		case errors.Is(reason, ErrRevoked):
+			p.remove(l.key, ErrRevoked)
+		case errors.Is(reason, ErrUnauthorized):
+			p.remove(l.key, ErrUnauthorized)
+		case errors.Is(reason, ErrTooManyRequests):
+			p.strikes[l.key]++
+
+			if p.strikes[l.key] > p.coolDownLimit {
+				p.remove(l.key, ErrTooManyRequests)
+
+				break
+			}
+
+			var (
+				delay = p.coolDown
+				ra    retryAfterer
+			)
+
+			if errors.As(reason, &ra) && ra.RetryAfter() > 0 {
+				delay = ra.RetryAfter()
+			}
+
+			p.coolUntil[l.key] = time.Now().Add(delay)
+		default:
+			delete(p.strikes, l.key) // the key works fine
+		}
+
+		p.notify()
+	})
+}
+
+// retryAfterer is implemented by errors that provide the delay requested by the server before the next request.
+type retryAfterer interface{ RetryAfter() time.Duration }
+
+// Retired returns the keys removed from the pool, with the reasons of removal.
+func (p *ClientsPool) Retired() []RetiredKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.retired)
+}
+
+// Acquire leases a client from the pool, chosen using the pool strategy. If all the keys are saturated (reached
+// the maximum number of in-flight requests), it blocks until any lease is released or the context is canceled.
+// ErrNoClients is returned if the pool is empty.
+func (p *ClientsPool) Acquire(ctx context.Context) (*Lease, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+
+		if key, found := p.pick(true); found {
+			p.inFlight[key]++
+
+			var lease = Lease{Client: p.clients[key], pool: p, key: key}
+
+			p.mu.Unlock()
+
+			return &lease, nil
+		}
+
+		if len(p.keys) == 0 { // all the keys were revoked or retired
+			p.mu.Unlock()
+
+			return nil, ErrNoClients
+		}
+
+		var (
+			wait  = p.changed
+			timer = time.NewTimer(time.Hour) // fires when the nearest cool-down ends
+		)
+
+		if until, ok := p.nearestCoolDownEnd(); ok {
+			timer.Reset(time.Until(until))
+		}
+
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, ctx.Err()
+		case <-wait:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+	}
+}
+
+// pick chooses the key using the pool strategy, initializing the clients and retiring the keys that reached the
+// quota limit. If limitInFlight is true, saturated keys are skipped. The caller must hold the lock.
+func (p *ClientsPool) pick(limitInFlight bool) (string, bool) { //nolint:funlen
+	var candidates = make([]int, 0, len(p.keys)) // indexes of the eligible keys
+
+	for _, key := range slices.Clone(p.keys) { // clone, since the keys can be removed during the iteration
+		var c = p.clients[key]
+
 		// if the client is not initialized, create a new one
 		if c == nil {
-			p.keys[key] = NewClient(key, p.opts...) // store in the pool
-			c = p.keys[key]
+			c = NewClient(key, p.opts...)
+			p.clients[key] = c // store in the pool
 		}
 
-		// return the client along with a cleanup function that removes the key from the pool
-		return c, func() {
-			p.mu.Lock()
-			defer p.mu.Unlock()
+		if used, _ := c.LastKnownUsedQuota(); p.quotaLimit > 0 && used >= p.quotaLimit {
+			p.remove(key, ErrQuotaLimitReached) // retire the key proactively, before it hits the API limit
+		}
+	}
 
-			// remove key from the pool when the client is no longer needed so any next Get() call will
-			// create a new client but using another key
-			delete(p.keys, key)
-		}, true
+	var now = time.Now()
+
+	for i, key := range p.keys {
+		if until, ok := p.coolUntil[key]; ok {
+			if now.Before(until) {
+				continue // the key is on the cool-down
+			}
+
+			delete(p.coolUntil, key) // reinstate the key
+		}
+
+		if limitInFlight && p.maxInFlight > 0 && p.inFlight[key] >= p.maxInFlight {
+			continue // the key is saturated
+		}
+
+		candidates = append(candidates, i)
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	switch p.strategy {
+	case StrategyRandom:
+		return p.keys[candidates[rand.IntN(len(candidates))]], true //nolint:gosec
+	case StrategyRoundRobin:
+		var chosen = candidates[0] // wrap around, if there are no candidates after the cursor
+
+		for _, i := range candidates {
+			if i >= p.next {
+				chosen = i
+
+				break
+			}
+		}
+
+		p.next = chosen + 1
+
+		return p.keys[chosen], true
+	case StrategyLeastInFlight:
+		return p.keys[slices.MinFunc(candidates, func(a, b int) int {
+			return cmp.Compare(p.inFlight[p.keys[a]], p.inFlight[p.keys[b]])
+		})], true
+	default: // StrategyMostRemaining
+		return p.keys[slices.MinFunc(candidates, func(a, b int) int {
+			// unknown usage is zero, so such clients are preferred
+			var usedA, _ = p.clients[p.keys[a]].LastKnownUsedQuota()
+			var usedB, _ = p.clients[p.keys[b]].LastKnownUsedQuota()
+
+			if c := cmp.Compare(usedA, usedB); c != 0 {
+				return c
+			}
+
+			return cmp.Compare(p.inFlight[p.keys[a]], p.inFlight[p.keys[b]])
+		})], true
+	}
+}
+
+// nearestCoolDownEnd returns the time when the nearest cool-down ends. The caller must hold the lock.
+func (p *ClientsPool) nearestCoolDownEnd() (nearest time.Time, found bool) {
+	for _, until := range p.coolUntil {
+		if !found || until.Before(nearest) {
+			nearest, found = until, true
+		}
+	}
+
+	return
+}
+
+// remove deletes the key from the pool and remembers the reason. The caller must hold the lock.
+func (p *ClientsPool) remove(key string, reason error) {
+	var idx = slices.Index(p.keys, key)
+	if idx < 0 {
+		return
+	}
+
+	delete(p.clients, key)
+	delete(p.inFlight, key)
+	delete(p.coolUntil, key)
+	delete(p.strikes, key)
+
+	p.retired = append(p.retired, RetiredKey{Key: key, Reason: reason})
+
+	p.keys = slices.Delete(p.keys, idx, idx+1)
+
+	if p.next > idx { // keep the round-robin cursor pointing to the same key
+		p.next--
+	}
+
+	p.notify()
+}
+
+// notify wakes up all the goroutines waiting in Acquire. The caller must hold the lock.
+func (p *ClientsPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// ParsePoolStrategy parses the pool strategy name.
+func ParsePoolStrategy(s string) (PoolStrategy, error) {
+	for _, strategy := range PoolStrategies() {
+		if string(strategy) == s {
+			return strategy, nil
+		}
 	}
 
-	return nil, func() {}, false
+	return "", fmt.Errorf("unsupported pool strategy: %s", s)
 }
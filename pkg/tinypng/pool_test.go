@@ -1,9 +1,18 @@
 package tinypng_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"slices"
 	"testing"
+	"time"
 
 	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
 )
 
 func TestClientsPool_Get(t *testing.T) {
@@ -63,3 +72,269 @@ func TestClientsPool_Get(t *testing.T) {
 
 	noRm() // noop
 }
+
+func TestNewClientsPool_ClientOptions(t *testing.T) {
+	t.Parallel()
+
+	const baseURL = "https://example.com/api"
+
+	for name, pool := range map[string]*tinypng.ClientsPool{
+		"client options": tinypng.NewClientsPool([]string{"foo"}, tinypng.WithBaseURL(baseURL)),
+		"pool options": tinypng.NewClientsPoolWithOptions([]string{"foo"},
+			tinypng.WithPoolClientOptions(tinypng.WithBaseURL(baseURL)),
+		),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, _, found := pool.Get()
+
+			if !found {
+				t.Fatal("client not found")
+			}
+
+			if client.BaseURL() != baseURL {
+				t.Errorf("expected base URL %q, got %q", baseURL, client.BaseURL())
+			}
+		})
+	}
+}
+
+func TestClientsPool_Get_QuotaAware(t *testing.T) {
+	t.Parallel()
+
+	var srv = tinypngtest.NewServer()
+	defer srv.Close()
+
+	var pool = tinypng.NewClientsPoolWithOptions([]string{"foo", "bar"},
+		tinypng.WithPoolClientOptions(tinypng.WithBaseURL(srv.URL), tinypng.WithHTTPClient(srv.Client())),
+		tinypng.WithPoolQuotaLimit(2),
+	)
+
+	// compress returns the API key of the client, used for the compression
+	var compress = func() string {
+		t.Helper()
+
+		client, _, found := pool.Get()
+		if !found {
+			t.Fatal("client not found")
+		}
+
+		if _, err := client.Compress(t.Context(), bytes.NewReader(pngImage(t))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return client.ApiKey()
+	}
+
+	// the clients without the known quota usage go first (in the order of the keys)
+	if key := compress(); key != "foo" {
+		t.Fatalf("expected foo, got %s", key)
+	}
+
+	if key := compress(); key != "bar" {
+		t.Fatalf("expected bar, got %s", key)
+	}
+
+	// both keys have used 1 compression, so the first one is used (the least used one is preferred)
+	if key := compress(); key != "foo" {
+		t.Fatalf("expected foo, got %s", key)
+	}
+
+	if key := compress(); key != "bar" {
+		t.Fatalf("expected bar, got %s", key)
+	}
+
+	// both keys have reached the limit, so they are retired
+	if client, _, found := pool.Get(); found || client != nil {
+		t.Fatal("expected no clients")
+	}
+}
+
+// pngImage returns a minimal valid PNG image.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestClientsPool_Acquire_Strategies(t *testing.T) {
+	t.Parallel()
+
+	var keys = []string{"foo", "bar", "baz"}
+
+	// acquire leases n clients (without releasing) and returns their keys
+	var acquire = func(t *testing.T, pool *tinypng.ClientsPool, n int) (got []string, leases []*tinypng.Lease) {
+		t.Helper()
+
+		for range n {
+			lease, err := pool.Acquire(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, leases = append(got, lease.Client.ApiKey()), append(leases, lease)
+		}
+
+		return
+	}
+
+	t.Run("round-robin", func(t *testing.T) {
+		t.Parallel()
+
+		var pool = tinypng.NewClientsPoolWithOptions(keys, tinypng.WithPoolStrategy(tinypng.StrategyRoundRobin))
+
+		got, leases := acquire(t, pool, 5)
+		assertSlicesEqual(t, []string{"foo", "bar", "baz", "foo", "bar"}, got)
+
+		leases[0].Revoke() // remove "foo"
+
+		got, _ = acquire(t, pool, 3)
+		assertSlicesEqual(t, []string{"baz", "bar", "baz"}, got)
+	})
+
+	t.Run("least-in-flight", func(t *testing.T) {
+		t.Parallel()
+
+		var pool = tinypng.NewClientsPoolWithOptions(keys, tinypng.WithPoolStrategy(tinypng.StrategyLeastInFlight))
+
+		got, leases := acquire(t, pool, 4)
+		assertSlicesEqual(t, []string{"foo", "bar", "baz", "foo"}, got)
+
+		leases[1].Release() // "bar" has no in-flight requests now
+		leases[1].Release() // no-op
+
+		got, _ = acquire(t, pool, 2)
+		assertSlicesEqual(t, []string{"bar", "bar"}, got)
+	})
+
+	t.Run("most-remaining (ties are broken by the in-flight requests)", func(t *testing.T) {
+		t.Parallel()
+
+		got, _ := acquire(t, tinypng.NewClientsPool(keys), 4)
+		assertSlicesEqual(t, []string{"foo", "bar", "baz", "foo"}, got)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		t.Parallel()
+
+		got, _ := acquire(t, tinypng.NewClientsPoolWithOptions(keys, tinypng.WithPoolStrategy(tinypng.StrategyRandom)), 10)
+
+		for _, key := range got {
+			if !slices.Contains(keys, key) {
+				t.Fatalf("unexpected key %s", key)
+			}
+		}
+	})
+}
+
+func TestClientsPool_Acquire_MaxInFlight(t *testing.T) {
+	t.Parallel()
+
+	var pool = tinypng.NewClientsPoolWithOptions([]string{"foo", "bar"}, tinypng.WithPoolMaxInFlight(1))
+
+	first, err := pool.Acquire(t.Context())
+	assertNoError(t, err)
+
+	second, err := pool.Acquire(t.Context())
+	assertNoError(t, err)
+
+	if first.Client.ApiKey() == second.Client.ApiKey() {
+		t.Fatal("expected different keys")
+	}
+
+	// all the keys are saturated, so Acquire blocks until the context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err = pool.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// releasing the lease unblocks the waiting Acquire
+	var acquired = make(chan *tinypng.Lease)
+
+	go func() {
+		lease, _ := pool.Acquire(t.Context())
+		acquired <- lease
+	}()
+
+	second.Release()
+
+	var third = <-acquired
+	if third == nil || third.Client.ApiKey() != second.Client.ApiKey() {
+		t.Fatal("expected the released key to be acquired")
+	}
+
+	// revoking all the keys makes the pool empty
+	first.Revoke()
+	third.Revoke()
+
+	if _, err = pool.Acquire(t.Context()); !errors.Is(err, tinypng.ErrNoClients) {
+		t.Fatalf("expected ErrNoClients, got %v", err)
+	}
+}
+
+// retryAfterError is the ErrTooManyRequests with the delay, requested by the server.
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string             { return "too many requests" }
+func (e retryAfterError) Unwrap() error             { return tinypng.ErrTooManyRequests }
+func (e retryAfterError) RetryAfter() time.Duration { return time.Duration(e) }
+
+func TestClientsPool_Lease_Fail(t *testing.T) {
+	t.Parallel()
+
+	var pool = tinypng.NewClientsPoolWithOptions([]string{"foo", "bar"},
+		tinypng.WithPoolStrategy(tinypng.StrategyRoundRobin),
+		tinypng.WithPoolCoolDown(time.Hour, 1),
+	)
+
+	acquire := func() *tinypng.Lease {
+		t.Helper()
+
+		lease, err := pool.Acquire(t.Context())
+		assertNoError(t, err)
+
+		return lease
+	}
+
+	// unauthorized keys are retired permanently
+	var foo = acquire()
+	assertEqual(t, "foo", foo.Client.ApiKey())
+	foo.Fail(fmt.Errorf("wrapped: %w", tinypng.ErrUnauthorized))
+
+	// rate-limited keys are put on the cool-down, using the Retry-After delay
+	var bar = acquire()
+	assertEqual(t, "bar", bar.Client.ApiKey())
+	bar.Fail(retryAfterError(30 * time.Millisecond))
+
+	var start = time.Now()
+
+	bar = acquire() // blocks until the cool-down ends
+	assertEqual(t, "bar", bar.Client.ApiKey())
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected to wait for the cool-down, waited %s", elapsed)
+	}
+
+	bar.Fail(retryAfterError(time.Millisecond)) // the 2nd consecutive cool-down retires the key
+
+	if _, err := pool.Acquire(t.Context()); !errors.Is(err, tinypng.ErrNoClients) {
+		t.Fatalf("expected ErrNoClients, got %v", err)
+	}
+
+	var retired = pool.Retired()
+
+	assertEqual(t, 2, len(retired))
+	assertEqual(t, "foo", retired[0].Key)
+	assertErrorIs(t, retired[0].Reason, tinypng.ErrUnauthorized)
+	assertEqual(t, "bar", retired[1].Key)
+	assertErrorIs(t, retired[1].Reason, tinypng.ErrTooManyRequests)
+}
@@ -0,0 +1,370 @@
+// Package tinypngtest provides a fake implementation of the `tinypng.com` API for testing purposes.
+//
+// The fake server implements the `/shrink` and `/output/*` endpoints, validates the API keys, tracks the
+// compression count (quota) per key, and "compresses" images by truncating them to the configured ratio (so
+// the returned images are NOT valid images - only their size is reduced).
+package tinypngtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG decoder for image.DecodeConfig
+	_ "image/png"  // register PNG decoder for image.DecodeConfig
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+type (
+	// Option is a functional option used to configure the Handler instance.
+	Option func(*Handler)
+
+	// output is a "compressed" image stored on the server.
+	output struct {
+		Data          []byte
+		Type          string
+		Width, Height int
+	}
+)
+
+// WithAPIKeys restricts the valid API keys to the given ones (by default, any non-empty key is valid).
+func WithAPIKeys(keys ...string) Option {
+	return func(h *Handler) {
+		for _, key := range keys {
+			h.keys[key] = struct{}{}
+		}
+	}
+}
+
+// WithQuotaLimit sets the maximum number of compressions per API key, after which the server responds with
+// the "429 Too Many Requests" status code (zero means no limit, which is the default).
+func WithQuotaLimit(limit uint64) Option {
+	return func(h *Handler) { h.quotaLimit = limit }
+}
+
+// WithCompressionRatio sets the ratio of the compressed image size to the original one (default is 0.7).
+func WithCompressionRatio(ratio float64) Option {
+	return func(h *Handler) { h.ratio = min(max(ratio, 0), 1) }
+}
+
+// Handler is a fake TinyPNG API implementation, which can be used with any HTTP server.
+type Handler struct {
+	keys       map[string]struct{} // valid API keys (empty means any key is valid)
+	quotaLimit uint64              // maximum number of compressions per key (0 means no limit)
+	ratio      float64             // ratio of the compressed image size to the original one
+
+	mu      sync.Mutex
+	used    map[string]uint64  // number of compressions used per API key
+	outputs map[string]*output // "compressed" images by their IDs
+
+	mux *http.ServeMux
+}
+
+var _ http.Handler = (*Handler)(nil) // ensure that Handler implements http.Handler
+
+// NewHandler creates a new fake TinyPNG API handler.
+func NewHandler(opts ...Option) *Handler {
+	var h = Handler{
+		keys:    make(map[string]struct{}),
+		ratio:   0.7, //nolint:mnd
+		used:    make(map[string]uint64),
+		outputs: make(map[string]*output),
+		mux:     http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	h.mux.HandleFunc("POST /shrink", h.withAuth(h.shrink))
+	h.mux.HandleFunc("GET /output/{id}", h.withAuth(h.output))
+	h.mux.HandleFunc("POST /output/{id}", h.withAuth(h.output))
+	h.mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeError(w, http.StatusNotFound, "NotFound", "The requested resource was not found.")
+	})
+
+	return &h
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { h.mux.ServeHTTP(w, r) }
+
+// UsedQuota returns the number of compressions used by the given API key.
+func (h *Handler) UsedQuota(apiKey string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.used[apiKey]
+}
+
+// withAuth wraps the handler function with the API key validation. The key is passed to the wrapped function.
+func (h *Handler) withAuth(next func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, key, ok := r.BasicAuth()
+		if !ok || user != "api" || key == "" {
+			writeError(w, http.StatusUnauthorized, "Unauthorized", "Credentials are invalid.")
+
+			return
+		}
+
+		if len(h.keys) > 0 {
+			if _, valid := h.keys[key]; !valid {
+				writeError(w, http.StatusUnauthorized, "Unauthorized", "Credentials are invalid.")
+
+				return
+			}
+		}
+
+		next(w, r, key)
+	}
+}
+
+// useQuota increments the compression count for the given key by n and returns the new value. If the limit
+// is reached, false is returned and the count stays unchanged.
+func (h *Handler) useQuota(key string, n uint64) (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.quotaLimit > 0 && h.used[key]+n > h.quotaLimit {
+		return h.used[key], false
+	}
+
+	h.used[key] += n
+
+	return h.used[key], true
+}
+
+// setCompressionCount sets the "Compression-Count" response header for the given key.
+func (h *Handler) setCompressionCount(w http.ResponseWriter, key string) {
+	w.Header().Set("Compression-Count", strconv.FormatUint(h.UsedQuota(key), 10))
+}
+
+// shrink handles the image upload ("compression") requests.
+func (h *Handler) shrink(w http.ResponseWriter, r *http.Request, key string) { //nolint:funlen
+	h.setCompressionCount(w, key)
+
+	data, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "Request body cannot be read.")
+
+		return
+	}
+
+	if len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "InputMissing", "Input file is empty.")
+
+		return
+	}
+
+	var mimeType = detectImageType(data)
+	if mimeType == "" {
+		writeError(w, http.StatusUnsupportedMediaType, "UnsupportedMediaType", "File type is not supported.")
+
+		return
+	}
+
+	count, ok := h.useQuota(key, 1)
+	if !ok {
+		writeError(w, http.StatusTooManyRequests, "TooManyRequests", "Your monthly limit has been exceeded.")
+
+		return
+	}
+
+	var (
+		out = output{Data: data[:max(1, int(float64(len(data))*h.ratio))], Type: mimeType}
+		id  = randomID()
+	)
+
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		out.Width, out.Height = cfg.Width, cfg.Height
+	}
+
+	h.mu.Lock()
+	h.outputs[id] = &out
+	h.mu.Unlock()
+
+	var scheme = "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	var outputURL = fmt.Sprintf("%s://%s/output/%s", scheme, r.Host, id)
+
+	w.Header().Set("Compression-Count", strconv.FormatUint(count, 10))
+	w.Header().Set("Location", outputURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"input": map[string]any{"size": len(data), "type": mimeType},
+		"output": map[string]any{
+			"size":   len(out.Data),
+			"type":   out.Type,
+			"width":  out.Width,
+			"height": out.Height,
+			"ratio":  float64(len(out.Data)) / float64(len(data)),
+			"url":    outputURL,
+		},
+	})
+}
+
+// output handles the requests for the "compressed" images (including resizing, conversion and storing).
+func (h *Handler) output(w http.ResponseWriter, r *http.Request, key string) { //nolint:funlen
+	h.setCompressionCount(w, key)
+
+	h.mu.Lock()
+	out, found := h.outputs[r.PathValue("id")]
+	h.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "NotFound", "The requested output was not found.")
+
+		return
+	}
+
+	var p struct {
+		Resize  *json.RawMessage `json:"resize"`
+		Convert *struct {
+			Type json.RawMessage `json:"type"` // string or array of strings
+		} `json:"convert"`
+		Store *struct {
+			Service string `json:"service"`
+			Region  string `json:"region"`
+			Path    string `json:"path"`
+		} `json:"store"`
+	}
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "BadRequest", "Request body is not a valid JSON.")
+
+			return
+		}
+	}
+
+	var (
+		mimeType = out.Type
+		cost     uint64 // resizing and conversion count as additional compressions
+	)
+
+	if p.Resize != nil {
+		cost++
+	}
+
+	if p.Convert != nil {
+		cost++
+
+		if t := convertType(p.Convert.Type); t != "" && t != "*/*" {
+			mimeType = t
+		}
+	}
+
+	if cost > 0 {
+		count, ok := h.useQuota(key, cost)
+		if !ok {
+			writeError(w, http.StatusTooManyRequests, "TooManyRequests", "Your monthly limit has been exceeded.")
+
+			return
+		}
+
+		w.Header().Set("Compression-Count", strconv.FormatUint(count, 10))
+	}
+
+	if p.Store != nil {
+		switch p.Store.Service {
+		case "s3":
+			w.Header().Set("Location", fmt.Sprintf("https://s3-%s.amazonaws.com/%s", p.Store.Region, p.Store.Path))
+		case "gcs":
+			w.Header().Set("Location", "https://storage.googleapis.com/"+p.Store.Path)
+		default:
+			writeError(w, http.StatusBadRequest, "BadRequest", "Unsupported storage service.")
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(out.Data)))
+
+	if out.Width > 0 && out.Height > 0 {
+		w.Header().Set("Image-Width", strconv.Itoa(out.Width))
+		w.Header().Set("Image-Height", strconv.Itoa(out.Height))
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(out.Data)
+}
+
+// Server is a fake TinyPNG API server, listening on a system-chosen port on the local loopback interface.
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+// NewServer starts and returns a new fake TinyPNG API server. The caller should call Close when finished,
+// to shut it down. The server URL can be used as the client base URL (see tinypng.WithBaseURL).
+func NewServer(opts ...Option) *Server {
+	var h = NewHandler(opts...)
+
+	return &Server{Server: httptest.NewServer(h), Handler: h}
+}
+
+// writeError writes the error response in the TinyPNG API format.
+func writeError(w http.ResponseWriter, code int, errName, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": errName, "message": message})
+}
+
+// detectImageType returns the MIME type of the image, or an empty string if the content is not a supported image.
+func detectImageType(data []byte) string {
+	// AVIF is not detected by the http.DetectContentType, so we need to check it manually
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" { //nolint:mnd
+		if brand := string(data[8:12]); brand == "avif" || brand == "avis" {
+			return "image/avif"
+		}
+	}
+
+	switch t := http.DetectContentType(data); t {
+	case "image/png", "image/jpeg", "image/webp":
+		return t
+	}
+
+	return ""
+}
+
+// convertType extracts the first MIME type from the "convert.type" value (a string or an array of strings).
+func convertType(raw json.RawMessage) string {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+		return list[0]
+	}
+
+	return ""
+}
+
+// randomID generates a random output ID.
+func randomID() string {
+	var b = make([]byte, 16) //nolint:mnd
+
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,175 @@
+package tinypngtest_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng"
+	"gh.tarampamp.am/tinifier/v5/pkg/tinypng/tinypngtest"
+)
+
+// pngImage returns a PNG image with the given dimensions.
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	assertNoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+
+	return buf.Bytes()
+}
+
+func newClient(srv *tinypngtest.Server, apiKey string) *tinypng.Client {
+	return tinypng.NewClient(apiKey, tinypng.WithBaseURL(srv.URL), tinypng.WithHTTPClient(srv.Client()))
+}
+
+func TestServer_CompressAndDownload(t *testing.T) {
+	t.Parallel()
+
+	var srv = tinypngtest.NewServer(tinypngtest.WithCompressionRatio(0.5))
+	defer srv.Close()
+
+	var (
+		client = newClient(srv, "foo-key")
+		img    = pngImage(t, 32, 16)
+	)
+
+	comp, err := client.Compress(t.Context(), bytes.NewReader(img))
+	assertNoError(t, err)
+
+	assertEqual(t, "image/png", comp.Type)
+	assertEqual(t, uint64(len(img)/2), comp.Size)
+	assertEqual(t, uint32(32), comp.Width)
+	assertEqual(t, uint32(16), comp.Height)
+	assertEqual(t, uint64(1), comp.UsedQuota)
+	assertEqual(t, true, strings.HasPrefix(comp.URL, srv.URL+"/output/"))
+
+	var out bytes.Buffer
+
+	assertNoError(t, comp.Download(t.Context(), &out))
+	assertEqual(t, len(img)/2, out.Len())
+	assertEqual(t, true, bytes.Equal(img[:len(img)/2], out.Bytes()))
+
+	// resizing and conversion count as additional compressions
+	assertNoError(t, comp.Download(t.Context(), &out,
+		tinypng.WithDownloadResize(tinypng.ResizeScale, 8, 0),
+		tinypng.WithDownloadConvert("image/webp"),
+	))
+	assertEqual(t, uint64(3), srv.UsedQuota("foo-key"))
+
+	used, err := client.UsedQuota(t.Context())
+	assertNoError(t, err)
+	assertEqual(t, uint64(3), used)
+}
+
+func TestServer_Store(t *testing.T) {
+	t.Parallel()
+
+	var srv = tinypngtest.NewServer()
+	defer srv.Close()
+
+	comp, err := newClient(srv, "foo-key").Compress(t.Context(), bytes.NewReader(pngImage(t, 1, 1)))
+	assertNoError(t, err)
+
+	location, err := comp.Store(t.Context(), tinypng.S3Store{Region: "us-west-1", Path: "bucket/file.png"})
+	assertNoError(t, err)
+	assertEqual(t, "https://s3-us-west-1.amazonaws.com/bucket/file.png", location)
+
+	location, err = comp.Store(t.Context(), tinypng.GCSStore{Path: "bucket/file.png"})
+	assertNoError(t, err)
+	assertEqual(t, "https://storage.googleapis.com/bucket/file.png", location)
+}
+
+func TestServer_Errors(t *testing.T) {
+	t.Parallel()
+
+	var srv = tinypngtest.NewServer(tinypngtest.WithAPIKeys("foo-key", "bar-key"), tinypngtest.WithQuotaLimit(1))
+	t.Cleanup(srv.Close) // parallel subtests are finished after the parent test function returns
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newClient(srv, "baz-key").Compress(t.Context(), bytes.NewReader(pngImage(t, 1, 1)))
+		assertErrorIs(t, err, tinypng.ErrUnauthorized)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newClient(srv, "foo-key").Compress(t.Context(), bytes.NewReader(nil))
+		assertErrorIs(t, err, tinypng.ErrBadRequest)
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newClient(srv, "foo-key").Compress(t.Context(), strings.NewReader("<html></html>"))
+		assertError(t, err)
+		assertEqual(t, true, strings.Contains(err.Error(), "UnsupportedMediaType"))
+	})
+
+	t.Run("too many requests", func(t *testing.T) {
+		t.Parallel()
+
+		var client = newClient(srv, "bar-key")
+
+		_, err := client.Compress(t.Context(), bytes.NewReader(pngImage(t, 1, 1)))
+		assertNoError(t, err)
+
+		_, err = client.Compress(t.Context(), bytes.NewReader(pngImage(t, 1, 1)))
+		assertErrorIs(t, err, tinypng.ErrTooManyRequests)
+
+		assertEqual(t, uint64(1), srv.UsedQuota("bar-key"))
+	})
+
+	t.Run("output not found", func(t *testing.T) {
+		t.Parallel()
+
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/output/foobar", http.NoBody)
+		req.SetBasicAuth("api", "foo-key")
+
+		resp, err := srv.Client().Do(req)
+		assertNoError(t, err)
+
+		_ = resp.Body.Close()
+
+		assertEqual(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func assertEqual[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+
+	if expected != actual {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func assertErrorIs(t *testing.T, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Fatalf("expected error to be %v, got %v", target, err)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}